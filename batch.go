@@ -0,0 +1,184 @@
+package gohive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+// Vector holds the values of one column of a Batch. Exactly one of the
+// value slices is set, depending on how hiveserver2 sends the column:
+// TINYINT, SMALLINT, INT and BIGINT in Int64s, FLOAT and DOUBLE in
+// Float64s, BOOLEAN in Bools, BINARY in Bytes and all other types, such as
+// DECIMAL, TIMESTAMP or complex types, as text in Strings.
+type Vector struct {
+	Name string
+	// Type is the Hive type name, as returned by
+	// sql.ColumnType.DatabaseTypeName.
+	Type     string
+	Int64s   []int64
+	Float64s []float64
+	Strings  []string
+	Bools    []bool
+	Bytes    [][]byte
+	// Nulls is true for NULL values, whose slot in the value slice holds
+	// the zero value. It is nil if the column has no NULL values.
+	Nulls []bool
+}
+
+// IsNull reports whether value i is NULL.
+func (v *Vector) IsNull(i int) bool {
+	return v.Nulls != nil && v.Nulls[i]
+}
+
+// Batch is the set of rows returned by one FetchResults call, in
+// column-oriented form. Its size is bounded by the batch DSN option.
+type Batch struct {
+	Columns []*Vector
+	Len     int
+}
+
+// Batches iterates over the batches of a query result. Like sql.Rows,
+// call Next until it returns false and then check Err.
+type Batches struct {
+	r      *rowSet
+	schema *hiveserver2.TTableSchema
+	batch  *Batch
+	err    error
+}
+
+// QueryBatches runs query and returns its result batch by batch, without
+// converting values to driver.Value. Call it through database/sql.Conn.Raw
+// and consume the batches before the callback returns.
+func (c *hiveConnection) QueryBatches(ctx context.Context, query string) (*Batches, error) {
+	resp, err := c.execute(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := newRows(c, resp.OperationHandle, ctx)
+	schema, err := r.Schema()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return &Batches{r: r, schema: schema}, nil
+}
+
+// QueryBatches runs query on conn and calls fn with every batch of its
+// result. It stops at the first error returned by fn.
+func QueryBatches(ctx context.Context, conn *sql.Conn, query string, fn func(*Batch) error) error {
+	return conn.Raw(func(dc interface{}) error {
+		hc, ok := dc.(*hiveConnection)
+		if !ok {
+			return fmt.Errorf("%T is not a gohive connection", dc)
+		}
+		b, err := hc.QueryBatches(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer b.Close()
+		for b.Next() {
+			if err := fn(b.Batch()); err != nil {
+				return err
+			}
+		}
+		return b.Err()
+	})
+}
+
+// Columns returns the names of the result columns.
+func (b *Batches) Columns() []string {
+	return b.r.Columns()
+}
+
+// Next fetches the next batch, and returns false at the end of the result
+// or on error.
+func (b *Batches) Next() bool {
+	if b.err != nil {
+		return false
+	}
+	cols, err := b.r.FetchColumns()
+	if err != nil {
+		if err != io.EOF {
+			b.err = err
+		}
+		b.batch = nil
+		return false
+	}
+	if len(cols) != len(b.schema.Columns) {
+		b.err = fmt.Errorf("FetchResults returned %d columns, the schema has %d", len(cols), len(b.schema.Columns))
+		return false
+	}
+	batch := &Batch{Columns: make([]*Vector, len(cols))}
+	for i, col := range cols {
+		v, length := newVector(col)
+		v.Name = b.schema.Columns[i].ColumnName
+		v.Type = b.r.ColumnTypeDatabaseTypeName(i)
+		batch.Columns[i] = v
+		batch.Len = length
+	}
+	b.batch = batch
+	return true
+}
+
+// Batch returns the batch fetched by the last call to Next. The slices of
+// a batch are not reused, so it stays valid after further calls to Next.
+func (b *Batches) Batch() *Batch {
+	return b.batch
+}
+
+// Err returns the error that stopped Next, if any.
+func (b *Batches) Err() error {
+	return b.err
+}
+
+// Close releases the result. It is safe to call Close more than once.
+func (b *Batches) Close() error {
+	return b.r.Close()
+}
+
+// newVector converts col into a Vector and returns its length. BIGINT,
+// DOUBLE, STRING and BINARY values are used as decoded, without copying.
+func newVector(col *hiveserver2.TColumn) (*Vector, int) {
+	v := &Vector{}
+	values, nulls, length := convertColumn(col)
+	switch values := values.(type) {
+	case []int8:
+		v.Int64s = make([]int64, length)
+		for i, x := range values {
+			v.Int64s[i] = int64(x)
+		}
+	case []int16:
+		v.Int64s = make([]int64, length)
+		for i, x := range values {
+			v.Int64s[i] = int64(x)
+		}
+	case []int32:
+		v.Int64s = make([]int64, length)
+		for i, x := range values {
+			v.Int64s[i] = int64(x)
+		}
+	case []int64:
+		v.Int64s = values
+	case []float64:
+		v.Float64s = values
+	case []string:
+		v.Strings = values
+	case []bool:
+		v.Bools = values
+	case [][]byte:
+		v.Bytes = values
+	}
+	for j := 0; j < length; j++ {
+		if isNull(nulls, j) {
+			if v.Nulls == nil {
+				v.Nulls = make([]bool, length)
+			}
+			v.Nulls[j] = true
+		}
+	}
+	return v, length
+}
@@ -0,0 +1,80 @@
+package gohive
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func TestQueryBatches(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("SELECT * FROM t", &hivetest.Result{
+		Columns: []hivetest.Column{
+			{Name: "id", Type: hiveserver2.TTypeId_BIGINT_TYPE},
+			{Name: "n", Type: hiveserver2.TTypeId_INT_TYPE},
+			{Name: "score", Type: hiveserver2.TTypeId_DOUBLE_TYPE},
+			{Name: "name", Type: hiveserver2.TTypeId_STRING_TYPE},
+			{Name: "ok", Type: hiveserver2.TTypeId_BOOLEAN_TYPE},
+		},
+		Rows: [][]interface{}{
+			{1, 10, 0.5, "a", true},
+			{2, nil, 1.5, nil, false},
+			{3, 30, nil, "c", nil},
+		},
+	})
+	db, err := sql.Open("hive", srv.DSN()+"&batch=2")
+	a.NoError(err)
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	a.NoError(err)
+	defer conn.Close()
+
+	var batches []*Batch
+	a.NoError(QueryBatches(context.Background(), conn, "SELECT * FROM t", func(b *Batch) error {
+		batches = append(batches, b)
+		return nil
+	}))
+	a.Equal([]*Batch{
+		{Len: 2, Columns: []*Vector{
+			{Name: "id", Type: "BIGINT_TYPE", Int64s: []int64{1, 2}},
+			{Name: "n", Type: "INT_TYPE", Int64s: []int64{10, 0}, Nulls: []bool{false, true}},
+			{Name: "score", Type: "DOUBLE_TYPE", Float64s: []float64{0.5, 1.5}},
+			{Name: "name", Type: "STRING_TYPE", Strings: []string{"a", ""}, Nulls: []bool{false, true}},
+			{Name: "ok", Type: "BOOLEAN_TYPE", Bools: []bool{true, false}},
+		}},
+		{Len: 1, Columns: []*Vector{
+			{Name: "id", Type: "BIGINT_TYPE", Int64s: []int64{3}},
+			{Name: "n", Type: "INT_TYPE", Int64s: []int64{30}},
+			{Name: "score", Type: "DOUBLE_TYPE", Float64s: []float64{0}, Nulls: []bool{true}},
+			{Name: "name", Type: "STRING_TYPE", Strings: []string{"c"}},
+			{Name: "ok", Type: "BOOLEAN_TYPE", Bools: []bool{false}, Nulls: []bool{true}},
+		}},
+	}, batches)
+	a.True(batches[0].Columns[1].IsNull(1))
+	a.False(batches[0].Columns[0].IsNull(1))
+}
+
+func TestQueryBatchesError(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("SELECT * FROM t", &hivetest.Result{Error: "Table not found t"})
+	db, err := sql.Open("hive", srv.DSN())
+	a.NoError(err)
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	a.NoError(err)
+	defer conn.Close()
+
+	err = QueryBatches(context.Background(), conn, "SELECT * FROM t", func(b *Batch) error {
+		return nil
+	})
+	a.Error(err)
+	a.Equal(1, srv.Calls("CloseOperation"))
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"sqlflow.org/gohive"
+)
+
+// client runs statements on a single hiveserver2 session, so that SET
+// statements affect the statements that follow.
+type client struct {
+	db     *sql.DB
+	conn   *sql.Conn
+	format string
+	// in is read by the REPL instead of os.Stdin if set.
+	in      io.ReadCloser
+	out     io.Writer
+	errOut  io.Writer
+	silent  bool
+	verbose bool
+	// progress is true while a progress line is displayed.
+	progress bool
+}
+
+var errNotConnected = errors.New("not connected, use !connect DSN")
+
+func (c *client) setFormat(format string) error {
+	if _, found := formatters[format]; !found {
+		return fmt.Errorf("unknown output format %s", format)
+	}
+	c.format = format
+	return nil
+}
+
+func (c *client) connect(dsn string) error {
+	c.close()
+	db, err := sql.Open("hive", dsn)
+	if err != nil {
+		return err
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return err
+	}
+	err = conn.Raw(func(dc interface{}) error {
+		if !c.silent {
+			dc.(interface{ SetProgressFunc(func(gohive.Progress)) }).SetProgressFunc(c.printProgress)
+		}
+		if c.verbose {
+			dc.(interface{ SetLogWriter(io.Writer) }).SetLogWriter(c.errOut)
+		}
+		return nil
+	})
+	if err != nil {
+		conn.Close()
+		db.Close()
+		return err
+	}
+	c.db, c.conn = db, conn
+	return nil
+}
+
+func (c *client) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.db.Close()
+		c.conn, c.db = nil, nil
+	}
+}
+
+// runScript runs the statements of script in order with gohive.RunScript.
+// It stops at the first error unless force is set, and reports whether all
+// statements succeeded.
+func (c *client) runScript(script string, force bool) bool {
+	_, err := gohive.RunScript(context.Background(), c.conn, script, gohive.ScriptOptions{
+		ContinueOnError: force,
+		Exec: func(ctx context.Context, stmt string) (int64, error) {
+			if err := c.run(stmt); err != nil {
+				fmt.Fprintf(c.errOut, "Error: %v\n", err)
+				return -1, err
+			}
+			return -1, nil
+		},
+	})
+	return err == nil
+}
+
+// run executes a statement and prints its result. Interrupting the process
+// cancels the context of the statement, on which the driver cancels or
+// closes the hiveserver2 operation, so that the query stops on the server.
+func (c *client) run(stmt string) error {
+	if c.conn == nil {
+		return errNotConnected
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	defer c.endProgress()
+
+	start := time.Now()
+	if !returnsRows(stmt) {
+		if _, err := c.conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+		c.endProgress()
+		c.info("No rows affected (%.3f seconds)", time.Since(start).Seconds())
+		return nil
+	}
+
+	rows, err := c.conn.QueryContext(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	n, err := c.printRows(rows)
+	if err != nil {
+		return err
+	}
+	c.info("%d row%s selected (%.3f seconds)", n, plural(n), time.Since(start).Seconds())
+	return nil
+}
+
+func (c *client) printRows(rows *sql.Rows) (int, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	f := formatters[c.format](c.out, cols, !c.silent)
+	n := 0
+	values := make([]interface{}, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return n, err
+		}
+		if n == 0 {
+			c.endProgress()
+		}
+		if err := f.Row(values); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	c.endProgress()
+	return n, f.Flush()
+}
+
+// info prints a message to errOut unless silent.
+func (c *client) info(format string, args ...interface{}) {
+	if !c.silent {
+		fmt.Fprintf(c.errOut, format+"\n", args...)
+	}
+}
+
+// printProgress overwrites the progress line with the latest progress of
+// the running operation.
+func (c *client) printProgress(p gohive.Progress) {
+	if p.Percentage < 0 {
+		return
+	}
+	line := fmt.Sprintf("%s: %3.0f%%", strings.TrimSuffix(p.State, "_STATE"), p.Percentage*100)
+	if p.Footer != "" {
+		line += " " + p.Footer
+	}
+	fmt.Fprintf(c.errOut, "\r%-60s", line)
+	c.progress = true
+}
+
+func (c *client) endProgress() {
+	if c.progress {
+		fmt.Fprintln(c.errOut)
+		c.progress = false
+	}
+}
+
+// returnsRows reports whether stmt produces a result set, judging by its
+// first keyword. Statements like SET k=v and INSERT are executed instead.
+func returnsRows(stmt string) bool {
+	fields := strings.Fields(strings.ToLower(stmt))
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "select", "with", "show", "describe", "desc", "explain", "values":
+		return true
+	case "set":
+		// SET and SET -v list variables, SET k prints the value of k.
+		return !strings.Contains(stmt, "=")
+	}
+	return false
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
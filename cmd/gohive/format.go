@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatter prints the rows of a result set.
+type formatter interface {
+	Row(values []interface{}) error
+	Flush() error
+}
+
+// formatters maps output format names to formatter constructors. header
+// tells whether to print column names.
+var formatters = map[string]func(w io.Writer, cols []string, header bool) formatter{
+	"table": newTableFormatter,
+	"csv":   newCSVFormatter,
+	"tsv":   newTSVFormatter,
+	"json":  newJSONFormatter,
+}
+
+// formatValue formats a value for the text formats.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// tableFormatter prints an ASCII table like beeline. Column widths depend
+// on all values, so rows are buffered until Flush.
+type tableFormatter struct {
+	w      io.Writer
+	cols   []string
+	header bool
+	rows   [][]string
+}
+
+func newTableFormatter(w io.Writer, cols []string, header bool) formatter {
+	return &tableFormatter{w: w, cols: cols, header: header}
+}
+
+func (f *tableFormatter) Row(values []interface{}) error {
+	row := make([]string, len(values))
+	for i, v := range values {
+		row[i] = formatValue(v)
+	}
+	f.rows = append(f.rows, row)
+	return nil
+}
+
+func (f *tableFormatter) Flush() error {
+	widths := make([]int, len(f.cols))
+	if f.header {
+		for i, c := range f.cols {
+			widths[i] = utf8.RuneCountInString(c)
+		}
+	}
+	for _, row := range f.rows {
+		for i, v := range row {
+			if n := utf8.RuneCountInString(v); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	var b strings.Builder
+	sep := func() {
+		for _, w := range widths {
+			b.WriteString("+" + strings.Repeat("-", w+2))
+		}
+		b.WriteString("+\n")
+	}
+	line := func(row []string) {
+		for i, v := range row {
+			b.WriteString("| " + v + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(v)+1))
+		}
+		b.WriteString("|\n")
+	}
+	sep()
+	if f.header {
+		line(f.cols)
+		sep()
+	}
+	for _, row := range f.rows {
+		line(row)
+	}
+	if len(f.rows) > 0 {
+		sep()
+	}
+	_, err := io.WriteString(f.w, b.String())
+	return err
+}
+
+type csvFormatter struct {
+	w *csv.Writer
+}
+
+func newCSVFormatter(w io.Writer, cols []string, header bool) formatter {
+	return newDelimitedFormatter(w, cols, header, ',')
+}
+
+func newTSVFormatter(w io.Writer, cols []string, header bool) formatter {
+	return newDelimitedFormatter(w, cols, header, '\t')
+}
+
+func newDelimitedFormatter(w io.Writer, cols []string, header bool, comma rune) formatter {
+	f := &csvFormatter{w: csv.NewWriter(w)}
+	f.w.Comma = comma
+	if header {
+		f.w.Write(cols)
+	}
+	return f
+}
+
+func (f *csvFormatter) Row(values []interface{}) error {
+	row := make([]string, len(values))
+	for i, v := range values {
+		row[i] = formatValue(v)
+	}
+	return f.w.Write(row)
+}
+
+func (f *csvFormatter) Flush() error {
+	f.w.Flush()
+	return f.w.Error()
+}
+
+// jsonFormatter prints a JSON object per row, keyed by column name.
+type jsonFormatter struct {
+	enc  *json.Encoder
+	cols []string
+}
+
+func newJSONFormatter(w io.Writer, cols []string, header bool) formatter {
+	return &jsonFormatter{enc: json.NewEncoder(w), cols: cols}
+}
+
+func (f *jsonFormatter) Row(values []interface{}) error {
+	// Write the keys in column order, which a map would not keep.
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.cols[i])
+		if s, ok := v.([]byte); ok {
+			v = string(s)
+		}
+		val, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return f.enc.Encode(json.RawMessage(b.String()))
+}
+
+func (f *jsonFormatter) Flush() error {
+	return nil
+}
@@ -0,0 +1,72 @@
+// Command gohive is an interactive client for hiveserver2, in the spirit of
+// beeline, built on the gohive database/sql driver.
+//
+// Usage:
+//
+//	gohive -u 'user:password@host:10000/db?auth=PLAIN' [-e query | -f file] [-format table|csv|tsv|json]
+//
+// Without -e and -f, gohive reads statements interactively. Statements end
+// with a semicolon and may span several lines. Lines starting with ! are
+// meta-commands, run !help to list them.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	_ "sqlflow.org/gohive"
+)
+
+func main() {
+	dsn := flag.String("u", os.Getenv("GOHIVE_DSN"), "gohive DSN or Hive JDBC URL, $GOHIVE_DSN by default")
+	query := flag.String("e", "", "run the given statements and exit")
+	file := flag.String("f", "", "run the statements in the given file and exit")
+	format := flag.String("format", "table", "output format: table, csv, tsv or json")
+	silent := flag.Bool("silent", false, "do not print progress, timings and headers")
+	verbose := flag.Bool("verbose", false, "print the operation logs of hiveserver2")
+	force := flag.Bool("force", false, "continue running a script after errors")
+	flag.Parse()
+
+	c := &client{
+		out:     os.Stdout,
+		errOut:  os.Stderr,
+		silent:  *silent,
+		verbose: *verbose,
+	}
+	if err := c.setFormat(*format); err != nil {
+		fatal(err)
+	}
+	if *dsn != "" {
+		if err := c.connect(*dsn); err != nil {
+			fatal(err)
+		}
+	}
+	defer c.close()
+
+	switch {
+	case *query != "":
+		if !c.runScript(*query, *force) {
+			c.close()
+			os.Exit(1)
+		}
+	case *file != "":
+		b, err := os.ReadFile(*file)
+		if err != nil {
+			fatal(err)
+		}
+		if !c.runScript(string(b), *force) {
+			c.close()
+			os.Exit(1)
+		}
+	default:
+		if err := c.repl(); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "gohive: %v\n", err)
+	os.Exit(1)
+}
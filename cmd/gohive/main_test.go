@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func TestComplete(t *testing.T) {
+	a := assert.New(t)
+	a.True(complete("SELECT 1;"))
+	a.True(complete("SELECT\n  1\n;  "))
+	a.True(complete("SET a=b;"))
+	a.False(complete("SELECT 1"))
+	a.False(complete("SELECT 'a;"))
+	a.False(complete("SELECT 1 -- done;"))
+	a.False(complete("SELECT 1 /* ;"))
+}
+
+func TestReturnsRows(t *testing.T) {
+	a := assert.New(t)
+	a.True(returnsRows("select 1"))
+	a.True(returnsRows("  WITH t AS (SELECT 1) SELECT * FROM t"))
+	a.True(returnsRows("SET hive.execution.engine"))
+	a.False(returnsRows("SET hive.execution.engine=tez"))
+	a.False(returnsRows("INSERT INTO t VALUES (1)"))
+	a.False(returnsRows(""))
+}
+
+func TestFormats(t *testing.T) {
+	cols := []string{"name", "age"}
+	rows := [][]interface{}{{"alice", int32(31)}, {"bob, jr", nil}}
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{"table", "+---------+------+\n| name    | age  |\n+---------+------+\n| alice   | 31   |\n| bob, jr | NULL |\n+---------+------+\n"},
+		{"csv", "name,age\nalice,31\n\"bob, jr\",NULL\n"},
+		{"tsv", "name\tage\nalice\t31\nbob, jr\tNULL\n"},
+		{"json", "{\"name\":\"alice\",\"age\":31}\n{\"name\":\"bob, jr\",\"age\":null}\n"},
+	} {
+		var b bytes.Buffer
+		f := formatters[tc.format](&b, cols, true)
+		for _, r := range rows {
+			assert.NoError(t, f.Row(r))
+		}
+		assert.NoError(t, f.Flush())
+		assert.Equal(t, tc.want, b.String(), tc.format)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("SET hive.exec.parallel=true", &hivetest.Result{})
+	srv.Handle("SELECT name FROM people", &hivetest.Result{
+		Columns: []hivetest.Column{{Name: "name", Type: hiveserver2.TTypeId_STRING_TYPE}},
+		Rows:    [][]interface{}{{"alice"}, {"bob"}},
+	})
+
+	var out, errOut bytes.Buffer
+	c := &client{out: &out, errOut: &errOut, silent: true}
+	a.NoError(c.setFormat("csv"))
+	a.ErrorIs(c.run("SELECT 1"), errNotConnected)
+	a.NoError(c.connect(srv.DSN() + "&pollInterval=1ms"))
+	defer c.close()
+
+	a.True(c.runScript("SET hive.exec.parallel=true;\nSELECT name FROM people;", false))
+	a.Equal("alice\nbob\n", out.String())
+	a.False(c.runScript("SELECT missing; SELECT name FROM people;", false))
+	a.Contains(errOut.String(), "no result for statement")
+	a.Equal("alice\nbob\n", out.String())
+}
+
+func TestREPLHistory(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("PLAIN")
+	defer srv.Close()
+	srv.Handle("SET hive.exec.parallel=true", &hivetest.Result{})
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	input := "!connect user:pa55word@" + srv.DSN() + "\nSET hive.exec.parallel=true;\n!format csv\n!quit\n"
+	var errOut bytes.Buffer
+	c := &client{in: io.NopCloser(strings.NewReader(input)), errOut: &errOut, silent: true}
+	a.NoError(c.repl())
+	defer c.close()
+	a.Empty(errOut.String())
+	a.Len(srv.Statements(), 1)
+
+	b, err := os.ReadFile(filepath.Join(home, ".gohive_history"))
+	a.NoError(err)
+	a.Contains(string(b), "SET hive.exec.parallel=true;")
+	a.Contains(string(b), "!format csv")
+	a.NotContains(string(b), "pa55word")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"sqlflow.org/gohive"
+)
+
+const (
+	prompt     = "gohive> "
+	contPrompt = "      > "
+)
+
+const helpText = `Statements end with a semicolon and may span several lines.
+Meta-commands:
+  !connect DSN      connect to hiveserver2, closing the current session
+  !tables           list the tables of the current database
+  !describe TABLE   describe the columns of TABLE
+  !format FORMAT    set the output format: table, csv, tsv or json
+  !help             print this help
+  !quit             exit
+`
+
+// repl reads statements and meta-commands interactively until EOF or
+// !quit. Complete statements and meta-commands are saved in
+// ~/.gohive_history, except !connect, whose DSN may hold a password.
+func (c *client) repl() error {
+	cfg := &readline.Config{
+		Prompt:                 prompt,
+		DisableAutoSaveHistory: true,
+		InterruptPrompt:        "^C",
+		EOFPrompt:              "!quit",
+		Stdin:                  c.in,
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		cfg.HistoryFile = filepath.Join(home, ".gohive_history")
+	}
+	rl, err := readline.NewEx(cfg)
+	if err != nil {
+		return err
+	}
+	defer rl.Close()
+	c.out, c.errOut = rl.Stdout(), rl.Stderr()
+
+	var buf []string
+	for {
+		line, err := rl.Readline()
+		if err == readline.ErrInterrupt {
+			// Ctrl-C discards the statement being typed.
+			buf = nil
+			rl.SetPrompt(prompt)
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(buf) == 0 && strings.HasPrefix(strings.TrimSpace(line), "!") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "!connect") {
+				rl.SaveHistory(line)
+			}
+			if quit := c.meta(strings.TrimSpace(line)); quit {
+				return nil
+			}
+			continue
+		}
+		if len(buf) == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+		buf = append(buf, line)
+		text := strings.Join(buf, "\n")
+		if !complete(text) {
+			rl.SetPrompt(contPrompt)
+			continue
+		}
+		rl.SaveHistory(text)
+		buf = nil
+		rl.SetPrompt(prompt)
+		c.runScript(text, false)
+	}
+}
+
+// meta runs a meta-command and reports whether to quit.
+func (c *client) meta(line string) bool {
+	fields := strings.Fields(strings.TrimPrefix(line, "!"))
+	if len(fields) == 0 {
+		return false
+	}
+	var err error
+	switch cmd, args := fields[0], fields[1:]; {
+	case cmd == "quit" || cmd == "exit" || cmd == "q":
+		return true
+	case cmd == "help":
+		fmt.Fprint(c.errOut, helpText)
+	case cmd == "connect" && len(args) == 1:
+		err = c.connect(args[0])
+	case cmd == "tables" && len(args) == 0:
+		err = c.run("SHOW TABLES")
+	case cmd == "describe" && len(args) == 1:
+		err = c.run("DESCRIBE " + args[0])
+	case cmd == "format" && len(args) == 1:
+		err = c.setFormat(args[0])
+	default:
+		err = fmt.Errorf("unknown command %s, run !help for help", line)
+	}
+	if err != nil {
+		fmt.Fprintf(c.errOut, "Error: %v\n", err)
+	}
+	return false
+}
+
+// sentinel is appended to input to find out whether it ends with a
+// complete statement.
+const sentinel = "gohive_sentinel"
+
+// complete reports whether text ends with a semicolon that terminates a
+// statement, rather than one in a string literal or a comment.
+func complete(text string) bool {
+	if !strings.HasSuffix(strings.TrimSpace(text), ";") {
+		return false
+	}
+	stmts := gohive.SplitStatements(text + "\n" + sentinel)
+	return len(stmts) > 0 && stmts[len(stmts)-1] == sentinel
+}
@@ -0,0 +1,120 @@
+package gohive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+const (
+	// compressorListConf is sent in TOpenSessionReq.Configuration to tell
+	// hiveserver2 which result set compressors the client understands.
+	compressorListConf = "hive.server2.thrift.resultset.compressor.list"
+	// compressorConf is returned in TOpenSessionResp.Configuration with
+	// the compressor hiveserver2 picked for this session.
+	compressorConf = "hive.server2.thrift.resultset.compressor"
+)
+
+// Decompressor decodes the compressed TRowSet.BinaryColumns payload
+// produced by a hiveserver2 result set compressor plugin.
+type Decompressor interface {
+	Decompress(src []byte) ([]byte, error)
+}
+
+// DecompressorFunc adapts an ordinary function to the Decompressor interface.
+type DecompressorFunc func(src []byte) ([]byte, error)
+
+func (f DecompressorFunc) Decompress(src []byte) ([]byte, error) {
+	return f(src)
+}
+
+var (
+	decompressorsMu sync.RWMutex
+	decompressors   = map[string]Decompressor{
+		"snappy": DecompressorFunc(decompressSnappy),
+		"zstd":   DecompressorFunc(decompressZstd),
+		"gzip":   DecompressorFunc(decompressGzip),
+	}
+)
+
+// RegisterDecompressor makes a decompressor available under the given
+// name, which can then be listed in the DSN option compressor.
+func RegisterDecompressor(name string, d Decompressor) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+	decompressors[name] = d
+}
+
+func lookupDecompressor(name string) (Decompressor, error) {
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+	d, found := decompressors[name]
+	if !found {
+		return nil, fmt.Errorf("unrecognized result set compressor: %s", name)
+	}
+	return d, nil
+}
+
+func decompressSnappy(src []byte) ([]byte, error) {
+	return snappy.Decode(nil, src)
+}
+
+// zstdDecoder is shared by all connections; DecodeAll is safe for
+// concurrent use. It is created on first use.
+var (
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderErr  error
+	zstdDecoderOnce sync.Once
+)
+
+func decompressZstd(src []byte) ([]byte, error) {
+	zstdDecoderOnce.Do(func() {
+		zstdDecoder, zstdDecoderErr = zstd.NewReader(nil)
+	})
+	if zstdDecoderErr != nil {
+		return nil, zstdDecoderErr
+	}
+	return zstdDecoder.DecodeAll(src, nil)
+}
+
+func decompressGzip(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// decodeBinaryColumns deserializes the TRowSet.BinaryColumns payload, which
+// hiveserver2 writes as count TColumn structs in the Thrift compact protocol.
+func decodeBinaryColumns(ctx context.Context, payload []byte, count int, d Decompressor) ([]*hiveserver2.TColumn, error) {
+	if d != nil {
+		var err error
+		if payload, err = d.Decompress(payload); err != nil {
+			return nil, fmt.Errorf("decompress binary columns failed: %v", err)
+		}
+	}
+	buf := thrift.NewTMemoryBufferLen(len(payload))
+	if _, err := buf.Write(payload); err != nil {
+		return nil, err
+	}
+	proto := thrift.NewTCompactProtocolConf(buf, &thrift.TConfiguration{})
+	columns := make([]*hiveserver2.TColumn, count)
+	for i := range columns {
+		col := hiveserver2.NewTColumn()
+		if err := col.Read(ctx, proto); err != nil {
+			return nil, fmt.Errorf("decode binary column %d failed: %v", i, err)
+		}
+		columns[i] = col
+	}
+	return columns, nil
+}
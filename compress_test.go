@@ -0,0 +1,68 @@
+package gohive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+func encodeColumns(t *testing.T, cols []*hiveserver2.TColumn) []byte {
+	buf := thrift.NewTMemoryBuffer()
+	proto := thrift.NewTCompactProtocolConf(buf, &thrift.TConfiguration{})
+	for _, c := range cols {
+		assert.NoError(t, c.Write(context.Background(), proto))
+	}
+	assert.NoError(t, proto.Flush(context.Background()))
+	return buf.Bytes()
+}
+
+func TestDecodeBinaryColumns(t *testing.T) {
+	a := assert.New(t)
+	cols := []*hiveserver2.TColumn{
+		{StringVal: &hiveserver2.TStringColumn{Values: []string{"a", "b"}, Nulls: []byte{}}},
+		{I64Val: &hiveserver2.TI64Column{Values: []int64{1, 2}, Nulls: []byte{}}},
+	}
+	raw := encodeColumns(t, cols)
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(raw)
+	w.Close()
+	enc, _ := zstd.NewWriter(nil)
+	zst := enc.EncodeAll(raw, nil)
+	enc.Close()
+
+	for _, tc := range []struct {
+		name    string
+		payload []byte
+		codec   string
+	}{
+		{"plain", raw, ""},
+		{"snappy", snappy.Encode(nil, raw), "snappy"},
+		{"zstd", zst, "zstd"},
+		{"gzip", gz.Bytes(), "gzip"},
+	} {
+		var d Decompressor
+		if tc.codec != "" {
+			var err error
+			d, err = lookupDecompressor(tc.codec)
+			a.NoError(err, tc.name)
+		}
+		got, err := decodeBinaryColumns(context.Background(), tc.payload, len(cols), d)
+		a.NoError(err, tc.name)
+		a.Equal([]string{"a", "b"}, got[0].GetStringVal().GetValues(), tc.name)
+		a.Equal([]int64{1, 2}, got[1].GetI64Val().GetValues(), tc.name)
+	}
+
+	_, err := lookupDecompressor("lz4")
+	a.Error(err)
+	_, err = decodeBinaryColumns(context.Background(), []byte("garbage"), 1, DecompressorFunc(decompressSnappy))
+	a.Error(err)
+}
@@ -3,36 +3,124 @@ package gohive
 import (
 	"context"
 	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"strings"
+	"time"
 
+	"github.com/apache/thrift/lib/go/thrift"
 	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
 )
 
 // hiveOptions for opened Hive sessions.
 type hiveOptions struct {
-	PollIntervalSeconds int64
-	BatchSize           int64
+	// PollInterval is the initial interval between GetOperationStatus
+	// calls, it doubles after each call up to MaxPollInterval.
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
+	// LongPolling means hiveserver2 blocks GetOperationStatus until the
+	// operation completes or hive.server2.long.polling.timeout expires.
+	LongPolling  bool
+	BatchSize    int64
+	Decompressor Decompressor
+	LogWriter    io.Writer
+	ProgressFunc func(Progress)
+	QueryTimeout time.Duration
 }
 
 type hiveConnection struct {
-	thrift  *hiveserver2.TCLIServiceClient
-	session *hiveserver2.TSessionHandle
-	options hiveOptions
-	ctx     context.Context
-}
-
-func (c *hiveConnection) Begin() (driver.Tx, error) {
-	return nil, nil
+	thrift    *hiveserver2.TCLIServiceClient
+	transport thrift.TTransport
+	session   *hiveserver2.TSessionHandle
+	options   hiveOptions
+	ctx       context.Context
+	// bad is set once the transport can no longer be trusted, e.g. after a
+	// socket timeout left a response unread.
+	bad bool
+	// txSupported caches whether the session can run transactions.
+	txSupported *bool
 }
 
 func (c *hiveConnection) Prepare(qry string) (driver.Stmt, error) {
-	if !c.isOpen() {
-		return nil, fmt.Errorf("driver: bad connection")
+	if !c.IsValid() {
+		return nil, driver.ErrBadConn
 	}
 	return &hiveStmt{hc: c, query: qry}, nil
 }
 
+// SetLogWriter tees the operation logs of queries subsequently run on this
+// connection to w. Call it through database/sql.Conn.Raw. A nil w disables it.
+func (c *hiveConnection) SetLogWriter(w io.Writer) {
+	c.options.LogWriter = w
+}
+
+type logWriterKey struct{}
+
+// WithLogWriter returns a copy of ctx that tees the operation logs of
+// queries run with it to w, instead of the writer set with SetLogWriter.
+// Logs are fetched on a best-effort basis and never fail the query.
+func WithLogWriter(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, logWriterKey{}, w)
+}
+
+// IsValid implements database/sql/driver.Validator, so that database/sql
+// discards broken connections instead of returning them to the pool.
+func (c *hiveConnection) IsValid() bool {
+	return c.isOpen() && !c.bad
+}
+
+// ResetSession implements database/sql/driver.SessionResetter. It is called
+// before a pooled connection is reused and rejects broken ones.
+func (c *hiveConnection) ResetSession(ctx context.Context) error {
+	if !c.IsValid() {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+// checkErr marks the connection as bad if err shows the transport is no
+// longer usable, e.g. hiveserver2 restarted or a socket timeout left a
+// response unread. It returns err unchanged.
+func (c *hiveConnection) checkErr(err error) error {
+	if isTransportError(err) {
+		c.bad = true
+	}
+	return err
+}
+
+// checkStatus marks the connection as bad if hiveserver2 no longer knows
+// its session, which happens after a restart.
+func (c *hiveConnection) checkStatus(status *hiveserver2.TStatus) {
+	if isInvalidSession(status) {
+		c.bad = true
+	}
+}
+
+// isTimeout reports whether err is a dial or socket timeout.
+func isTimeout(err error) bool {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	var te thrift.TTransportException
+	return errors.As(err, &te) && te.TypeId() == thrift.TIMED_OUT
+}
+
+// isTransportError reports whether err comes from the connection rather
+// than from hiveserver2.
+func isTransportError(err error) bool {
+	var te thrift.TTransportException
+	var ne net.Error
+	return isTimeout(err) || errors.As(err, &te) || errors.As(err, &ne) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func isInvalidSession(status *hiveserver2.TStatus) bool {
+	return status != nil && strings.Contains(status.GetErrorMessage(), "Invalid SessionHandle")
+}
+
 func (c *hiveConnection) isOpen() bool {
 	return c.session != nil
 }
@@ -46,18 +134,30 @@ func (c *hiveConnection) Ping(ctx context.Context) (err error) {
 
 	resp, err := c.thrift.GetInfo(ctx, getInfoReq)
 
+	// GetInfo has no side effects, so it is always safe to report a broken
+	// connection as driver.ErrBadConn.
 	if err != nil {
-		return fmt.Errorf("Error in GetInfo: %v", err)
+		if isTransportError(c.checkErr(err)) {
+			return driver.ErrBadConn
+		}
+		return fmt.Errorf("Error in GetInfo: %w", err)
 	}
 
 	if !isSuccessStatus(resp.Status) {
-		return fmt.Errorf("Error from server: %s", resp.Status.String())
+		c.checkStatus(resp.Status)
+		if c.bad {
+			return driver.ErrBadConn
+		}
+		return fmt.Errorf("Error from server: %s", statusMessage(resp.Status))
 	}
 
 	return nil
 }
 
 func (c *hiveConnection) Close() error {
+	if c.transport != nil {
+		defer c.transport.Close()
+	}
 	if c.isOpen() {
 		closeReq := hiveserver2.NewTCloseSessionReq()
 		closeReq.SessionHandle = c.session
@@ -71,6 +171,15 @@ func (c *hiveConnection) Close() error {
 	return nil
 }
 
+// abort closes a connection that failed to open. CloseSession is bounded by
+// operationCallTimeout, as the context of Connect may be done already.
+func (c *hiveConnection) abort() {
+	ctx, cancel := context.WithTimeout(context.Background(), operationCallTimeout)
+	defer cancel()
+	c.ctx = ctx
+	c.Close()
+}
+
 func removeLastSemicolon(s string) string {
 	s = strings.TrimSpace(s)
 	n := len(s)
@@ -81,17 +190,33 @@ func removeLastSemicolon(s string) string {
 }
 
 func (c *hiveConnection) execute(ctx context.Context, query string, args []driver.NamedValue) (*hiveserver2.TExecuteStatementResp, error) {
+	if !c.IsValid() {
+		return nil, driver.ErrBadConn
+	}
+	query, err := bindArgs(query, args)
+	if err != nil {
+		return nil, err
+	}
 	executeReq := hiveserver2.NewTExecuteStatementReq()
 	executeReq.SessionHandle = c.session
-	executeReq.Statement = removeLastSemicolon(query)
+	executeReq.Statement = removeLastSemicolon(substituteHiveVars(ctx, query))
+	executeReq.RunAsync = true
+	executeReq.ConfOverlay = confOverlay(ctx, args)
+	executeReq.QueryTimeout = queryTimeout(ctx, c.options.QueryTimeout)
 
 	resp, err := c.thrift.ExecuteStatement(c.ctx, executeReq)
 	if err != nil {
-		return nil, fmt.Errorf("Error in ExecuteStatement: %+v, %v", resp, err)
+		return nil, fmt.Errorf("Error in ExecuteStatement: %+v, %w", resp, c.checkErr(err))
 	}
 
+	// A transport error may surface after hiveserver2 received the statement,
+	// so only a rejected session is safe to retry on another connection.
 	if !isSuccessStatus(resp.Status) {
-		return nil, fmt.Errorf("Error from server: %s", resp.Status.String())
+		c.checkStatus(resp.Status)
+		if c.bad {
+			return nil, driver.ErrBadConn
+		}
+		return nil, fmt.Errorf("Error from server: %s", statusMessage(resp.Status))
 	}
 	return resp, nil
 }
@@ -101,7 +226,9 @@ func (c *hiveConnection) QueryContext(ctx context.Context, query string, args []
 	if err != nil {
 		return nil, err
 	}
-	return newRows(c.thrift, resp.OperationHandle, c.options, ctx), nil
+	r := newRows(c, resp.OperationHandle, ctx)
+	r.reportIDs()
+	return r, nil
 }
 
 func (c *hiveConnection) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
@@ -109,7 +236,36 @@ func (c *hiveConnection) ExecContext(ctx context.Context, query string, args []d
 	if err != nil {
 		return nil, err
 	}
-	return newHiveResult(resp.OperationHandle), nil
+	// The statement runs asynchronously, wait for it to complete.
+	r := newRows(c, resp.OperationHandle, ctx)
+	defer r.Close()
+	r.reportIDs()
+	if err := r.waitFinished(); err != nil {
+		return nil, err
+	}
+	return newHiveResult(r), nil
+}
+
+// queryTimeout returns the server-side timeout in seconds for a query, the
+// shorter of the configured timeout and the deadline of ctx. Zero means no
+// timeout.
+func queryTimeout(ctx context.Context, timeout time.Duration) int64 {
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := time.Until(deadline); timeout <= 0 || d < timeout {
+			timeout = d
+		}
+	}
+	if timeout <= 0 {
+		return 0
+	}
+	// Round up, hiveserver2 treats zero as no timeout.
+	return int64((timeout + time.Second - 1) / time.Second)
+}
+
+// statusMessage formats a failed TStatus for error messages, as
+// TStatus.String prints the address of ErrorMessage instead of the message.
+func statusMessage(p *hiveserver2.TStatus) string {
+	return fmt.Sprintf("%s: %s", p.GetStatusCode(), p.GetErrorMessage())
 }
 
 func isSuccessStatus(p *hiveserver2.TStatus) bool {
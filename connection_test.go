@@ -0,0 +1,86 @@
+package gohive
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(int64(0), queryTimeout(context.Background(), 0))
+	a.Equal(int64(30), queryTimeout(context.Background(), 30*time.Second))
+	a.Equal(int64(2), queryTimeout(context.Background(), 1500*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	a.Equal(int64(10), queryTimeout(ctx, 0))
+	a.Equal(int64(10), queryTimeout(ctx, time.Minute))
+	a.Equal(int64(5), queryTimeout(ctx, 5*time.Second))
+}
+
+func TestCheckErrMarksTimeoutsBad(t *testing.T) {
+	a := assert.New(t)
+	c := &hiveConnection{session: hiveserver2.NewTSessionHandle()}
+	c.checkErr(errors.New("Error from server"))
+	a.True(c.IsValid())
+
+	_, err := net.DialTimeout("tcp", "10.255.255.1:10000", time.Nanosecond)
+	a.True(isTimeout(err))
+	a.True(isTimeout(thrift.NewTTransportException(thrift.TIMED_OUT, "read timeout")))
+	c.checkErr(thrift.NewTTransportExceptionFromError(err))
+	a.False(c.IsValid())
+	a.Equal(driver.ErrBadConn, c.ResetSession(context.Background()))
+}
+
+func TestInvalidSessionIsBadConn(t *testing.T) {
+	a := assert.New(t)
+	c := &hiveConnection{session: hiveserver2.NewTSessionHandle()}
+	a.NoError(c.ResetSession(context.Background()))
+	a.False(isTransportError(errors.New("Error from server")))
+	a.True(isTransportError(io.EOF))
+
+	msg := "Invalid SessionHandle: SessionHandle [5f3d]"
+	c.checkStatus(&hiveserver2.TStatus{
+		StatusCode:   hiveserver2.TStatusCode_ERROR_STATUS,
+		ErrorMessage: &msg,
+	})
+	a.False(c.IsValid())
+	_, err := c.execute(context.Background(), "SELECT 1", nil)
+	a.Equal(driver.ErrBadConn, err)
+}
+
+func TestCloseClosesTransport(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	cfg, err := ParseDSN(srv.DSN())
+	a.NoError(err)
+	var conns []*trackedConn
+	cfg.Dial = trackingDial(&conns)
+
+	db := sql.OpenDB(NewConnector(cfg))
+	a.NoError(db.Ping())
+	a.Equal(1, srv.Sessions())
+	a.NoError(db.Close())
+	a.Equal(0, srv.Sessions())
+	a.Len(conns, 1)
+	a.True(conns[0].closed)
+}
+
+func TestStatusMessage(t *testing.T) {
+	msg := "Table not found t"
+	status := &hiveserver2.TStatus{StatusCode: hiveserver2.TStatusCode_ERROR_STATUS, ErrorMessage: &msg}
+	assert.Equal(t, "ERROR_STATUS: Table not found t", statusMessage(status))
+	assert.Equal(t, "SUCCESS_STATUS: ", statusMessage(hiveserver2.NewTStatus()))
+}
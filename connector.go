@@ -0,0 +1,284 @@
+package gohive
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql/driver"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	bgohive "github.com/beltran/gohive"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+// connector implements database/sql/driver.Connector.
+type connector struct {
+	cfg *Config
+}
+
+// NewConnector returns a connector for use with database/sql.OpenDB. Unlike
+// a DSN, cfg can carry a custom Dial function, a TLSConfig and a
+// PasswordFunc.
+func NewConnector(cfg *Config) driver.Connector {
+	return &connector{cfg: cfg}
+}
+
+func (c *connector) Driver() driver.Driver {
+	return drv{}
+}
+
+// Connect opens a hiveserver2 session. Dialing, the TLS and SASL handshakes
+// and OpenSession are all bounded by ctx and by Config.ConnectTimeout.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cfg := c.cfg
+	if cfg.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.ConnectTimeout)
+		defer cancel()
+	}
+	passwd := cfg.Passwd
+	if cfg.PasswordFunc != nil {
+		p, err := cfg.PasswordFunc(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get password failed: %v", err)
+		}
+		passwd = p
+	}
+
+	if cfg.Replay != "" {
+		transport, err := newReplayTransport(cfg.Replay)
+		if err != nil {
+			return nil, err
+		}
+		return c.openSession(ctx, transport, passwd)
+	}
+
+	if cfg.TransportMode == httpTransportMode {
+		transport, err := c.httpTransport(passwd)
+		if err != nil {
+			return nil, err
+		}
+		return c.openSession(ctx, transport, passwd)
+	}
+
+	netConn, host, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := c.openSocketSession(ctx, netConn, host, passwd)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// dial connects to the first reachable address of the comma-separated
+// Config.Addr and returns the connection with the host it reached.
+func (c *connector) dial(ctx context.Context) (net.Conn, string, error) {
+	var err error
+	for _, addr := range strings.Split(c.cfg.Addr, ",") {
+		var conn net.Conn
+		if conn, err = c.dialFunc()(ctx, "tcp", addr); err != nil {
+			continue
+		}
+		host, _, e := net.SplitHostPort(addr)
+		if e != nil {
+			host = addr
+		}
+		if c.cfg.TLSConfig == nil {
+			return conn, host, nil
+		}
+		tlsConfig := c.cfg.TLSConfig
+		if tlsConfig.ServerName == "" && !tlsConfig.InsecureSkipVerify {
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = host
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			continue
+		}
+		return tlsConn, host, nil
+	}
+	return nil, "", err
+}
+
+func (c *connector) dialFunc() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c.cfg.Dial != nil {
+		return c.cfg.Dial
+	}
+	return (&net.Dialer{Timeout: c.cfg.ConnectTimeout, KeepAlive: c.cfg.KeepAlive}).DialContext
+}
+
+// httpTransport returns a transport for hiveserver2 running with
+// hive.server2.transport.mode=http. Only the first address is used.
+func (c *connector) httpTransport(passwd string) (thrift.TTransport, error) {
+	cfg := c.cfg
+	scheme := "http"
+	if cfg.TLSConfig != nil {
+		scheme = "https"
+	}
+	addr := strings.Split(cfg.Addr, ",")[0]
+	u := fmt.Sprintf("%s://%s/%s", scheme, addr, strings.TrimPrefix(cfg.HTTPPath, "/"))
+	client := &http.Client{
+		Transport: &http.Transport{DialContext: c.dialFunc(), TLSClientConfig: cfg.TLSConfig},
+		Timeout:   cfg.SocketTimeout,
+	}
+	transport, err := thrift.NewTHttpClientWithOptions(u, thrift.THttpClientOptions{Client: client})
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.Auth {
+	case "NOSASL":
+	case "PLAIN", "LDAP":
+		cred := base64.StdEncoding.EncodeToString([]byte(cfg.User + ":" + passwd))
+		transport.(*thrift.THttpClient).SetHeader("Authorization", "Basic "+cred)
+	default:
+		return nil, fmt.Errorf("auth mechanism %s is not supported in http transport mode", cfg.Auth)
+	}
+	return transport, nil
+}
+
+// openSocketSession runs the SASL handshake and OpenSession over netConn.
+// TSocket resets the socket deadline on every read, so cancelling ctx
+// closes the socket instead to unblock pending reads.
+func (c *connector) openSocketSession(ctx context.Context, netConn net.Conn, host, passwd string) (*hiveConnection, error) {
+	cfg := c.cfg
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			netConn.Close()
+		case <-done:
+		}
+	}()
+
+	// The SASL transport wraps socket, so SocketTimeout covers both.
+	socket := thrift.NewTSocketFromConnConf(netConn, &thrift.TConfiguration{
+		ConnectTimeout: cfg.ConnectTimeout,
+		SocketTimeout:  cfg.SocketTimeout,
+	})
+	var transport thrift.TTransport
+	if cfg.Auth == "NOSASL" {
+		transport = thrift.NewTBufferedTransport(socket, 4096)
+		if transport == nil {
+			return nil, fmt.Errorf("BufferedTransport is nil")
+		}
+	} else if cfg.Auth == "PLAIN" || cfg.Auth == "GSSAPI" || cfg.Auth == "LDAP" {
+		saslCfg := map[string]string{
+			"username": cfg.User,
+			"password": passwd,
+		}
+		if cfg.Principal != "" {
+			saslCfg["service"] = strings.SplitN(cfg.Principal, "/", 2)[0]
+		}
+		bgTransport, err := bgohive.NewTSaslTransport(socket, host, cfg.Auth, saslCfg, bgohive.DEFAULT_MAX_LENGTH)
+		if err != nil {
+			return nil, fmt.Errorf("create SasalTranposrt failed: %v", err)
+		}
+		bgTransport.SetMaxLength(uint32(cfg.Batch))
+		bgTransport.OpeningContext = ctx
+		if err = bgTransport.Open(); err != nil {
+			return nil, err
+		}
+		transport = bgTransport
+	} else {
+		return nil, fmt.Errorf("unrecognized auth mechanism: %s", cfg.Auth)
+	}
+	return c.openSession(ctx, transport, passwd)
+}
+
+// openSession issues OpenSession over an open transport.
+func (c *connector) openSession(ctx context.Context, transport thrift.TTransport, passwd string) (*hiveConnection, error) {
+	cfg := c.cfg
+	if cfg.Record != "" {
+		rt, err := newRecordingTransport(transport, cfg.Record)
+		if err != nil {
+			transport.Close()
+			return nil, err
+		}
+		transport = rt
+	}
+	protocol := thrift.NewTBinaryProtocolFactoryDefault()
+	client := hiveserver2.NewTCLIServiceClientFactory(transport, protocol)
+	s := hiveserver2.NewTOpenSessionReq()
+	s.ClientProtocol = hiveserver2.TProtocolVersion_HIVE_CLI_SERVICE_PROTOCOL_V6
+	if cfg.User != "" {
+		s.Username = &cfg.User
+		if passwd != "" {
+			s.Password = &passwd
+		}
+	}
+	config := make(map[string]string)
+	for k, v := range cfg.SessionCfg {
+		config[k] = v
+	}
+	for k, v := range cfg.HiveVars {
+		config[setHiveVarPrefix+k] = v
+	}
+	for k, v := range cfg.HiveConf {
+		config[setHiveConfPrefix+k] = v
+	}
+	if cfg.DBName != "" {
+		config["use:database"] = cfg.DBName
+	}
+	if cfg.LongPollTimeout > 0 {
+		config[longPollTimeoutConf] = fmt.Sprintf("%dms", cfg.LongPollTimeout.Milliseconds())
+	}
+	if cfg.Compressor != "" {
+		config[compressorListConf] = cfg.Compressor
+	}
+	s.Configuration = config
+	session, err := client.OpenSession(ctx, s)
+	if err != nil {
+		transport.Close()
+		return nil, err
+	}
+	conn := &hiveConnection{
+		thrift:    client,
+		transport: transport,
+		session:   session.SessionHandle,
+		ctx:       context.Background(),
+	}
+	if ctx.Err() != nil {
+		conn.abort()
+		return nil, ctx.Err()
+	}
+	if !isSuccessStatus(session.Status) {
+		conn.abort()
+		return nil, fmt.Errorf("OpenSession failed: %s", statusMessage(session.Status))
+	}
+
+	options := hiveOptions{
+		PollInterval:    cfg.PollInterval,
+		MaxPollInterval: cfg.MaxPollInterval,
+		LongPolling:     cfg.LongPollTimeout > 0,
+		BatchSize:       int64(cfg.Batch),
+		QueryTimeout:    cfg.QueryTimeout,
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = defaultPollInterval
+	}
+	if options.MaxPollInterval <= 0 {
+		options.MaxPollInterval = defaultMaxPollInterval
+	}
+	if options.MaxPollInterval < options.PollInterval {
+		options.MaxPollInterval = options.PollInterval
+	}
+	if name, found := session.Configuration[compressorConf]; found && name != "" {
+		d, err := lookupDecompressor(name)
+		if err != nil {
+			conn.abort()
+			return nil, err
+		}
+		options.Decompressor = d
+	}
+	conn.options = options
+	return conn, nil
+}
@@ -0,0 +1,110 @@
+package gohive
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func TestConnectorHonoursContext(t *testing.T) {
+	a := assert.New(t)
+	// A server that accepts connections but never answers OpenSession.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	a.NoError(err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	c := NewConnector(&Config{Addr: ln.Addr().String(), Auth: "NOSASL", Batch: defaultBatchSize})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.Connect(ctx)
+	a.Error(err)
+	a.Less(time.Since(start), 5*time.Second)
+}
+
+func TestConnectorDial(t *testing.T) {
+	a := assert.New(t)
+	dialErr := errors.New("dial refused")
+	cfg := &Config{
+		Addr: "hiveserver:10000",
+		Auth: "NOSASL",
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			a.Equal("hiveserver:10000", addr)
+			return nil, dialErr
+		},
+	}
+	_, err := NewConnector(cfg).Connect(context.Background())
+	a.Equal(dialErr, err)
+
+	c, err := drv{}.OpenConnector("127.0.0.1:10000/mydb")
+	a.NoError(err)
+	_, ok := c.Driver().(driver.DriverContext)
+	a.True(ok)
+}
+
+func TestConnectorReleasesFailedSession(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.SetSessionConfig(map[string]string{compressorConf: "bogus"})
+	cfg, err := ParseDSN(srv.DSN())
+	a.NoError(err)
+	var conns []*trackedConn
+	cfg.Dial = trackingDial(&conns)
+	cfg.Record = filepath.Join(t.TempDir(), "session.json")
+
+	_, err = NewConnector(cfg).Connect(context.Background())
+	a.Error(err)
+	a.Equal(1, srv.Calls("OpenSession"))
+	a.Equal(1, srv.Calls("CloseSession"))
+	a.Equal(0, srv.Sessions())
+	a.Len(conns, 1)
+	a.True(conns[0].closed)
+	// Closing the recording terminates its JSON array.
+	b, err := os.ReadFile(cfg.Record)
+	a.NoError(err)
+	a.Contains(string(b), "CloseSession")
+	a.True(strings.HasSuffix(string(b), "]\n"))
+}
+
+// trackedConn records whether the driver closed a network connection.
+type trackedConn struct {
+	net.Conn
+	closed bool
+}
+
+func (c *trackedConn) Close() error {
+	c.closed = true
+	return c.Conn.Close()
+}
+
+// trackingDial dials with net.Dialer and appends the connections to conns.
+func trackingDial(conns *[]*trackedConn) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c := &trackedConn{Conn: conn}
+		*conns = append(*conns, c)
+		return c, nil
+	}
+}
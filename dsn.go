@@ -1,11 +1,15 @@
 package gohive
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/url"
-	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -15,98 +19,297 @@ type Config struct {
 	DBName     string
 	Auth       string
 	Batch      int
+	Compressor string
+	// QueryTimeout makes hiveserver2 cancel queries that run longer.
+	QueryTimeout time.Duration
+	// PollInterval is the initial interval between GetOperationStatus
+	// calls, which backs off exponentially up to MaxPollInterval.
+	PollInterval    time.Duration
+	MaxPollInterval time.Duration
+	// LongPollTimeout sets hive.server2.long.polling.timeout, the time
+	// GetOperationStatus blocks on the server waiting for completion.
+	LongPollTimeout time.Duration
+	// ConnectTimeout bounds dialing and opening the session.
+	ConnectTimeout time.Duration
+	// SocketTimeout bounds every read and write on the connection.
+	SocketTimeout time.Duration
+	// KeepAlive is the TCP keep-alive period, negative to disable.
+	KeepAlive  time.Duration
 	SessionCfg map[string]string
-}
+	// HiveVars and HiveConf are substituted by hiveserver2 into
+	// ${hivevar:name} and ${hiveconf:name} references in queries.
+	HiveVars map[string]string
+	HiveConf map[string]string
+	// Principal is the Kerberos principal of hiveserver2, whose service
+	// name is used for GSSAPI authentication.
+	Principal string
+	// TransportMode is "binary" by default, or "http" for hiveserver2
+	// running with hive.server2.transport.mode=http under HTTPPath.
+	TransportMode string
+	HTTPPath      string
+	// JDBCParams keeps the session variables of a JDBC URL that gohive
+	// does not interpret.
+	JDBCParams map[string]string
+	// Record is a file to which every Thrift call and its reply are written
+	// as JSON. Passwords are redacted. A connection opened while another one
+	// records to the file writes to file-2, file-3, ... instead, with the
+	// number inserted before the extension.
+	Record string
+	// Replay is a file written by Record. Connections then answer calls
+	// with the recorded replies instead of connecting to Addr.
+	Replay string
+	// TLSConfig enables TLS on the connection if not nil. In a DSN, tls=true
+	// sets an empty config, which verifies hiveserver2 against the system
+	// roots. Other fields can only be set with NewConnector.
+	TLSConfig *tls.Config
 
-var (
-	// Regexp syntax: https://github.com/google/re2/wiki/Syntax
-	reDSN        = regexp.MustCompile(`(.+@)?([^@|^?]+)\\?(.*)`)
-	reUserPasswd = regexp.MustCompile(`([^:@]+)(:[^:@]+)?@`)
-)
+	// The following options cannot be expressed in a DSN and are only
+	// honoured by connectors created with NewConnector.
+
+	// Dial opens the network connection to Addr, net.Dialer by default.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// PasswordFunc, if set, is called on every new connection and
+	// overrides Passwd, so that credentials can be rotated.
+	PasswordFunc func(ctx context.Context) (string, error)
+}
 
 const (
 	sessionConfPrefix = "session."
+	hiveVarPrefix     = "hivevar."
+	hiveConfPrefix    = "hiveconf."
+	jdbcParamPrefix   = "jdbc."
 	authConfName      = "auth"
 	defaultAuth       = "NOSASL"
 	batchSizeName     = "batch"
 	defaultBatchSize  = 10000
+	compressorName    = "compressor"
+	principalName     = "principal"
+	transportModeName = "transportMode"
+	httpPathName      = "httpPath"
+	queryTimeoutName  = "queryTimeout"
+	recordName        = "record"
+	replayName        = "replay"
+	tlsName           = "tls"
+
+	pollIntervalName       = "pollInterval"
+	defaultPollInterval    = 100 * time.Millisecond
+	maxPollIntervalName    = "maxPollInterval"
+	defaultMaxPollInterval = 5 * time.Second
+	longPollTimeoutName    = "longPollTimeout"
+	longPollTimeoutConf    = "hive.server2.long.polling.timeout"
+	connectTimeoutName     = "connectTimeout"
+	socketTimeoutName      = "socketTimeout"
+	keepAliveName          = "keepAlive"
+)
+
+// Bytes that are percent-encoded in each part of a DSN, besides '%' itself.
+const (
+	userSpecials   = ":@/?"
+	passwdSpecials = "@/?"
+	addrSpecials   = "@/?"
+	dbNameSpecials = "@/?"
+	querySpecials  = "&=+;# "
 )
 
-// ParseDSN requires DSN names in the format [user[:password]@]addr/dbname.
+// ParseDSN requires DSN names in the format
+// [user[:password]@]addr[/dbname][?param1=value1&...&paramN=valueN],
+// or Hive JDBC URLs as accepted by ParseJDBC. addr may list several
+// comma-separated host:port pairs that are tried in order. Percent-encoded
+// bytes are decoded, so any credentials can be passed as written by
+// FormatDSN.
 func ParseDSN(dsn string) (*Config, error) {
-	// Please read https://play.golang.org/p/_CSLvl1AxOX before code review.
-	sub := reDSN.FindStringSubmatch(dsn)
-	if len(sub) != 4 {
-		return nil, fmt.Errorf("The DSN %s doesn't match [user[:password]@]addr[/dbname][?auth=AUTH_MECHANISM]", dsn)
+	if isJDBC(dsn) {
+		return ParseJDBC(dsn)
 	}
-	addr := ""
-	dbname := ""
-	loc := strings.IndexRune(sub[2], '/')
-	if loc > -1 {
-		addr = sub[2][:loc]
-		dbname = sub[2][loc+1:]
-	} else {
-		addr = sub[2]
-	}
-	user := ""
-	passwd := ""
-	up := reUserPasswd.FindStringSubmatch(sub[1])
-	if len(up) == 3 {
-		user = up[1]
-		if len(up[2]) > 0 {
-			passwd = up[2][1:]
+	rest, query := dsn, ""
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		rest, query = rest[:i], rest[i+1:]
+	}
+	user, passwd := "", ""
+	if i := strings.LastIndexByte(rest, '@'); i >= 0 {
+		user = rest[:i]
+		if j := strings.IndexByte(user, ':'); j >= 0 {
+			user, passwd = user[:j], user[j+1:]
 		}
+		rest = rest[i+1:]
+	}
+	addr, dbname := rest, ""
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		addr, dbname = rest[:i], rest[i+1:]
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("The DSN %s doesn't match [user[:password]@]addr[/dbname][?auth=AUTH_MECHANISM]", dsn)
 	}
 
-	auth := defaultAuth
-	batch := defaultBatchSize
-	sc := make(map[string]string)
-	if len(sub[3]) > 0 && sub[3][0] == '?' {
-		qry, _ := url.ParseQuery(sub[3][1:])
+	cfg := &Config{
+		User:       dsnUnescape(user),
+		Passwd:     dsnUnescape(passwd),
+		Addr:       dsnUnescape(addr),
+		DBName:     dsnUnescape(dbname),
+		Auth:       defaultAuth,
+		Batch:      defaultBatchSize,
+		SessionCfg: make(map[string]string),
+		HiveVars:   make(map[string]string),
+		HiveConf:   make(map[string]string),
+		JDBCParams: make(map[string]string),
+	}
+	if len(query) > 0 {
+		qry, _ := url.ParseQuery(query)
 
 		if v, found := qry[authConfName]; found {
-			auth = v[0]
+			cfg.Auth = v[0]
 		}
 		if v, found := qry[batchSizeName]; found {
 			bch, err := strconv.Atoi(v[0])
 			if err != nil {
 				return nil, err
 			}
-			batch = bch
+			cfg.Batch = bch
+		}
+		if v, found := qry[tlsName]; found {
+			enabled, err := strconv.ParseBool(v[0])
+			if err != nil {
+				return nil, err
+			}
+			if enabled {
+				cfg.TLSConfig = &tls.Config{}
+			}
+		}
+		for name, p := range cfg.strings() {
+			if v, found := qry[name]; found {
+				*p = v[0]
+			}
+		}
+		for name, d := range cfg.durations() {
+			if v, found := qry[name]; found {
+				dur, err := time.ParseDuration(v[0])
+				if err != nil {
+					return nil, err
+				}
+				*d = dur
+			}
 		}
 
 		for k, v := range qry {
-			if strings.HasPrefix(k, sessionConfPrefix) {
-				sc[k[len(sessionConfPrefix):]] = v[0]
+			for prefix, m := range cfg.maps() {
+				if strings.HasPrefix(k, prefix) {
+					m[k[len(prefix):]] = v[0]
+				}
 			}
 		}
 	}
 
-	return &Config{
-		User:       user,
-		Passwd:     passwd,
-		Addr:       addr,
-		DBName:     dbname,
-		Auth:       auth,
-		Batch:      batch,
-		SessionCfg: sc,
-	}, nil
+	return cfg, nil
 }
 
-// FormatDSN outputs a string in the format "user:password@address?auth=xxx"
+// strings maps the DSN names of string options to Config fields.
+func (cfg *Config) strings() map[string]*string {
+	return map[string]*string{
+		compressorName:    &cfg.Compressor,
+		principalName:     &cfg.Principal,
+		transportModeName: &cfg.TransportMode,
+		httpPathName:      &cfg.HTTPPath,
+		recordName:        &cfg.Record,
+		replayName:        &cfg.Replay,
+	}
+}
+
+// maps maps the DSN key prefixes of map options to Config fields.
+func (cfg *Config) maps() map[string]map[string]string {
+	return map[string]map[string]string{
+		sessionConfPrefix: cfg.SessionCfg,
+		hiveVarPrefix:     cfg.HiveVars,
+		hiveConfPrefix:    cfg.HiveConf,
+		jdbcParamPrefix:   cfg.JDBCParams,
+	}
+}
+
+// durations maps the DSN names of duration options to Config fields.
+func (cfg *Config) durations() map[string]*time.Duration {
+	return map[string]*time.Duration{
+		queryTimeoutName:    &cfg.QueryTimeout,
+		pollIntervalName:    &cfg.PollInterval,
+		maxPollIntervalName: &cfg.MaxPollInterval,
+		longPollTimeoutName: &cfg.LongPollTimeout,
+		connectTimeoutName:  &cfg.ConnectTimeout,
+		socketTimeoutName:   &cfg.SocketTimeout,
+		keepAliveName:       &cfg.KeepAlive,
+	}
+}
+
+// FormatDSN outputs a string in the format
+// "user:password@address/dbname?batch=N&auth=xxx&...", which ParseDSN reads
+// back into an equal Config. Reserved bytes are percent-encoded and keys
+// are written in a fixed order. As ':' is encoded in user names, a user such
+// as "hive2://h" does not make the DSN read as a JDBC URL. A TLSConfig is
+// written as tls=true, which drops its fields. Dial and PasswordFunc cannot
+// be expressed in a DSN and are left out.
 func (cfg *Config) FormatDSN() string {
-	dsn := fmt.Sprintf("%s:%s@%s", cfg.User, cfg.Passwd, cfg.Addr)
+	dsn := ""
+	if len(cfg.User) > 0 || len(cfg.Passwd) > 0 {
+		dsn = dsnEscape(cfg.User, userSpecials)
+		if len(cfg.Passwd) > 0 {
+			dsn += ":" + dsnEscape(cfg.Passwd, passwdSpecials)
+		}
+		dsn += "@"
+	}
+	dsn += dsnEscape(cfg.Addr, addrSpecials)
 	if len(cfg.DBName) > 0 {
-		dsn = fmt.Sprintf("%s/%s", dsn, cfg.DBName)
+		dsn += "/" + dsnEscape(cfg.DBName, dbNameSpecials)
 	}
-	dsn += fmt.Sprintf("?batch=%d", cfg.Batch)
-	if len(cfg.Auth) > 0 {
-		dsn += fmt.Sprintf("&auth=%s", cfg.Auth)
+	param := func(sep, k, v string) {
+		dsn += sep + dsnEscape(k, querySpecials) + "=" + dsnEscape(v, querySpecials)
 	}
-	if len(cfg.SessionCfg) > 0 {
-		for k, v := range cfg.SessionCfg {
-			dsn += fmt.Sprintf("&%s%s=%s", sessionConfPrefix, k, v)
+	param("?", batchSizeName, strconv.Itoa(cfg.Batch))
+	param("&", authConfName, cfg.Auth)
+	for _, name := range []string{compressorName, principalName, transportModeName, httpPathName, recordName, replayName} {
+		if v := *cfg.strings()[name]; len(v) > 0 {
+			param("&", name, v)
+		}
+	}
+	if cfg.TLSConfig != nil {
+		param("&", tlsName, "true")
+	}
+	for _, name := range []string{queryTimeoutName, pollIntervalName, maxPollIntervalName,
+		longPollTimeoutName, connectTimeoutName, socketTimeoutName, keepAliveName} {
+		if d := *cfg.durations()[name]; d != 0 {
+			param("&", name, d.String())
+		}
+	}
+	for _, prefix := range []string{sessionConfPrefix, hiveVarPrefix, hiveConfPrefix, jdbcParamPrefix} {
+		m := cfg.maps()[prefix]
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			param("&", prefix+k, m[k])
 		}
 	}
 	return dsn
 }
+
+// dsnEscape percent-encodes '%' and the bytes in specials, keeping the
+// rest of s readable.
+func dsnEscape(s, specials string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '%' || c < 0x20 || c == 0x7f || strings.IndexByte(specials, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// dsnUnescape decodes percent-encoded bytes. Strings that are not valid
+// percent-encoding are returned as is, for DSNs written before escaping.
+func dsnUnescape(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
@@ -2,6 +2,7 @@ package gohive
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -109,6 +110,17 @@ func TestFormatDSNWithDBName(t *testing.T) {
 	assert.Equal(t, ds2, ds)
 }
 
+func TestFormatDSNWithJDBCLikeUser(t *testing.T) {
+	for _, user := range []string{"hive2://h", "jdbc:hive2://h"} {
+		cfg := &Config{User: user, Addr: "h", Batch: defaultBatchSize, Auth: defaultAuth}
+		dsn := cfg.FormatDSN()
+		assert.False(t, isJDBC(dsn), dsn)
+		cfg2, err := ParseDSN(dsn)
+		assert.NoError(t, err)
+		assert.Equal(t, user, cfg2.User)
+	}
+}
+
 func TestFormatDSNWithoutDBName(t *testing.T) {
 	ds := "user:passwd@127.0.0.1?batch=100&auth=NOSASL"
 	cfg, e := ParseDSN(ds)
@@ -117,3 +129,147 @@ func TestFormatDSNWithoutDBName(t *testing.T) {
 	ds2 := cfg.FormatDSN()
 	assert.Equal(t, ds2, ds)
 }
+
+func TestParseDSNWithCompressor(t *testing.T) {
+	ds := "user:passwd@127.0.0.1?batch=100&auth=NOSASL&compressor=snappy,zstd"
+	cfg, e := ParseDSN(ds)
+	assert.Nil(t, e)
+	assert.Equal(t, cfg.Compressor, "snappy,zstd")
+	assert.Equal(t, cfg.FormatDSN(), ds)
+}
+
+func TestParseDSNWithQueryTimeout(t *testing.T) {
+	ds := "user:passwd@127.0.0.1?batch=100&auth=NOSASL&queryTimeout=1m30s"
+	cfg, e := ParseDSN(ds)
+	assert.Nil(t, e)
+	assert.Equal(t, cfg.QueryTimeout, 90*time.Second)
+	assert.Equal(t, cfg.FormatDSN(), ds)
+
+	_, e = ParseDSN("127.0.0.1?queryTimeout=abc")
+	assert.Error(t, e)
+}
+
+func TestParseDSNWithPolling(t *testing.T) {
+	ds := "user:passwd@127.0.0.1?batch=100&auth=NOSASL&pollInterval=50ms&maxPollInterval=2s&longPollTimeout=10s"
+	cfg, e := ParseDSN(ds)
+	assert.Nil(t, e)
+	assert.Equal(t, cfg.PollInterval, 50*time.Millisecond)
+	assert.Equal(t, cfg.MaxPollInterval, 2*time.Second)
+	assert.Equal(t, cfg.LongPollTimeout, 10*time.Second)
+	assert.Equal(t, cfg.FormatDSN(), ds)
+}
+
+func TestParseDSNWithSocketOptions(t *testing.T) {
+	ds := "user:passwd@127.0.0.1?batch=100&auth=NOSASL&connectTimeout=5s&socketTimeout=1m0s&keepAlive=-1ns"
+	cfg, e := ParseDSN(ds)
+	assert.Nil(t, e)
+	assert.Equal(t, cfg.ConnectTimeout, 5*time.Second)
+	assert.Equal(t, cfg.SocketTimeout, time.Minute)
+	assert.True(t, cfg.KeepAlive < 0)
+	assert.Equal(t, cfg.FormatDSN(), ds)
+}
+
+func TestParseDSNWithTLS(t *testing.T) {
+	ds := "127.0.0.1?batch=10000&auth=PLAIN&tls=true"
+	cfg, e := ParseDSN(ds)
+	assert.Nil(t, e)
+	assert.NotNil(t, cfg.TLSConfig)
+	assert.Equal(t, ds, cfg.FormatDSN())
+
+	cfg, e = ParseDSN("jdbc:hive2://h:10000/db;ssl=true")
+	assert.Nil(t, e)
+	cfg, e = ParseDSN(cfg.FormatDSN())
+	assert.Nil(t, e)
+	assert.NotNil(t, cfg.TLSConfig)
+
+	cfg, e = ParseDSN("127.0.0.1?tls=false")
+	assert.Nil(t, e)
+	assert.Nil(t, cfg.TLSConfig)
+	_, e = ParseDSN("127.0.0.1?tls=yes")
+	assert.Error(t, e)
+}
+
+func TestParseDSNWithHiveVars(t *testing.T) {
+	cfg, e := ParseDSN("127.0.0.1?hivevar.dt=2020-01-01&hiveconf.hive.exec.parallel=true&session.a=b")
+	assert.Nil(t, e)
+	assert.Equal(t, map[string]string{"dt": "2020-01-01"}, cfg.HiveVars)
+	assert.Equal(t, map[string]string{"hive.exec.parallel": "true"}, cfg.HiveConf)
+	assert.Equal(t, map[string]string{"a": "b"}, cfg.SessionCfg)
+
+	cfg2, e := ParseDSN(cfg.FormatDSN())
+	assert.Nil(t, e)
+	assert.Equal(t, cfg.HiveVars, cfg2.HiveVars)
+	assert.Equal(t, cfg.HiveConf, cfg2.HiveConf)
+}
+
+func TestFormatDSNEscaping(t *testing.T) {
+	cfg := &Config{
+		User:       "us@r:x",
+		Passwd:     "p@ss:w/rd?&=%",
+		Addr:       "host1:10000,host2:10000",
+		DBName:     "my db",
+		Auth:       "PLAIN",
+		Batch:      10,
+		SessionCfg: map[string]string{"b": "x&y=z", "a": "1+1"},
+		HiveVars:   map[string]string{"dt": "2020-01-01"},
+		HiveConf:   map[string]string{},
+		JDBCParams: map[string]string{},
+	}
+	dsn := cfg.FormatDSN()
+	assert.Equal(t, "us%40r%3Ax:p%40ss:w%2Frd%3F&=%25@host1:10000,host2:10000/my db"+
+		"?batch=10&auth=PLAIN&session.a=1%2B1&session.b=x%26y%3Dz&hivevar.dt=2020-01-01", dsn)
+	cfg2, e := ParseDSN(dsn)
+	assert.Nil(t, e)
+	assert.Equal(t, cfg, cfg2)
+
+	// DSNs written before escaping keep working.
+	cfg, e = ParseDSN("root:p%zz@127.0.0.1")
+	assert.Nil(t, e)
+	assert.Equal(t, "p%zz", cfg.Passwd)
+}
+
+func FuzzParseDSN(f *testing.F) {
+	f.Add("root:root@127.0.0.1/mnist?auth=PLAIN")
+	f.Add("usr:pswd@hiveserver/mydb?batch=200&auth=PLAIN&session.mapreduce_job_quenename=mr")
+	f.Add("a%zz:b%41@h1:1,h2:2/db?queryTimeout=1.5h&hivevar.x=1&jdbc.retries=3")
+	f.Add("jdbc:hive2://h:1/db;user=u;retries=3?a=b#c=d")
+	f.Add("jdbc:hive2://h:1/db;ssl=true;transportMode=http")
+	f.Add("h:1?tls=1")
+	f.Fuzz(func(t *testing.T, dsn string) {
+		cfg, err := ParseDSN(dsn)
+		if err != nil {
+			return
+		}
+		cfg2, err := ParseDSN(cfg.FormatDSN())
+		assert.NoError(t, err, cfg.FormatDSN())
+		assert.Equal(t, cfg, cfg2, cfg.FormatDSN())
+	})
+}
+
+func FuzzFormatDSN(f *testing.F) {
+	f.Add("usr", "pswd", "hiveserver:10000", "mydb", "PLAIN", 200, "mapreduce.job.queuename", "mr")
+	f.Add("u@:/?", "p@:/?&=%", "h", "d/b?", "", -1, "k&=+; ", "v&=+; #")
+	f.Add("hive2://h", "", "h", "", "", 0, "k", "v")
+	f.Add("jdbc:hive2://h", "p", "h", "", "", 0, "k", "v")
+	f.Add("", "", "hive2:", "/d", "", 0, "k", "v")
+	f.Fuzz(func(t *testing.T, user, passwd, addr, dbname, auth string, batch int, key, value string) {
+		if addr == "" {
+			return
+		}
+		cfg := &Config{
+			User:       user,
+			Passwd:     passwd,
+			Addr:       addr,
+			DBName:     dbname,
+			Auth:       auth,
+			Batch:      batch,
+			SessionCfg: map[string]string{key: value},
+			HiveVars:   map[string]string{key: value},
+			HiveConf:   map[string]string{key: value},
+			JDBCParams: map[string]string{key: value},
+		}
+		cfg2, err := ParseDSN(cfg.FormatDSN())
+		assert.NoError(t, err, cfg.FormatDSN())
+		assert.Equal(t, cfg, cfg2, cfg.FormatDSN())
+	})
+}
@@ -0,0 +1,317 @@
+package gohive
+
+import (
+	"bufio"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat is the output format of Export.
+type ExportFormat int
+
+const (
+	// CSV writes delimited text, comma-separated by default.
+	CSV ExportFormat = iota
+	// TSV writes delimited text, tab-separated by default.
+	TSV
+	// JSONLines writes a JSON object per row, keyed by column name.
+	JSONLines
+)
+
+// QuoteMode controls the quoting of CSV and TSV fields.
+type QuoteMode int
+
+const (
+	// QuoteMinimal quotes fields containing the delimiter, quotes, line
+	// breaks, or equal to the NULL representation.
+	QuoteMinimal QuoteMode = iota
+	// QuoteAll quotes all fields except NULLs.
+	QuoteAll
+	// QuoteNone never quotes, and escapes the delimiter, line breaks and
+	// backslashes with a backslash instead, like Hive text tables.
+	QuoteNone
+)
+
+// hiveTimestampLayout is how hiveserver2 formats TIMESTAMP values.
+const hiveTimestampLayout = "2006-01-02 15:04:05.999999999"
+
+// ExportOptions controls Export.
+type ExportOptions struct {
+	Format ExportFormat
+	// Delimiter separates CSV and TSV fields, ',' or '\t' by default.
+	Delimiter rune
+	Quote     QuoteMode
+	// Null represents NULL in CSV and TSV, an empty field by default.
+	Null string
+	// Header writes the column names as the first CSV or TSV row.
+	Header bool
+	// TimeLayout reformats TIMESTAMP values with a time.Format layout.
+	// They are written as hiveserver2 returns them by default.
+	TimeLayout string
+}
+
+// exportSource iterates over the rows of a result set.
+type exportSource interface {
+	// columns returns the column names and Hive type names.
+	columns() ([]string, []string, error)
+	// next reads the next row into dest, or returns io.EOF.
+	next(dest []interface{}) error
+}
+
+// Export writes the rows of a query to w, and returns the number of rows
+// written. Rows are streamed, so memory use does not depend on the size of
+// the result. Values are formatted according to their Hive types: DECIMAL
+// values keep their precision, FLOAT values are not widened to double
+// precision, and ARRAY, MAP, STRUCT and UNION values, which hiveserver2
+// returns as JSON, are embedded as JSON in JSONLines.
+func Export(w io.Writer, rows *sql.Rows, opts ExportOptions) (int64, error) {
+	return export(w, sqlSource{rows}, opts)
+}
+
+// ExportDriverRows is like Export for the driver.Rows returned by the
+// QueryContext method of a driver connection, obtained through
+// database/sql.Conn.Raw.
+func ExportDriverRows(w io.Writer, rows driver.Rows, opts ExportOptions) (int64, error) {
+	return export(w, driverSource{rows}, opts)
+}
+
+type sqlSource struct {
+	rows *sql.Rows
+}
+
+func (s sqlSource) columns() ([]string, []string, error) {
+	types, err := s.rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, len(types))
+	typeNames := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.Name()
+		typeNames[i] = t.DatabaseTypeName()
+	}
+	return names, typeNames, nil
+}
+
+func (s sqlSource) next(dest []interface{}) error {
+	if !s.rows.Next() {
+		if err := s.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	ptrs := make([]interface{}, len(dest))
+	for i := range dest {
+		ptrs[i] = &dest[i]
+	}
+	return s.rows.Scan(ptrs...)
+}
+
+type driverSource struct {
+	rows driver.Rows
+}
+
+func (s driverSource) columns() ([]string, []string, error) {
+	names := s.rows.Columns()
+	typeNames := make([]string, len(names))
+	if r, ok := s.rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		for i := range names {
+			typeNames[i] = r.ColumnTypeDatabaseTypeName(i)
+		}
+	}
+	return names, typeNames, nil
+}
+
+func (s driverSource) next(dest []interface{}) error {
+	values := make([]driver.Value, len(dest))
+	if err := s.rows.Next(values); err != nil {
+		return err
+	}
+	for i, v := range values {
+		dest[i] = v
+	}
+	return nil
+}
+
+func export(w io.Writer, src exportSource, opts ExportOptions) (int64, error) {
+	names, typeNames, err := src.columns()
+	if err != nil {
+		return 0, err
+	}
+	for i, t := range typeNames {
+		typeNames[i] = strings.TrimSuffix(strings.ToUpper(t), "_TYPE")
+	}
+	if opts.Delimiter == 0 {
+		opts.Delimiter = ','
+		if opts.Format == TSV {
+			opts.Delimiter = '\t'
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	e := &exporter{w: bw, opts: opts, names: names, types: typeNames}
+	if opts.Header && opts.Format != JSONLines {
+		fields := make([]interface{}, len(names))
+		for i, n := range names {
+			fields[i] = n
+		}
+		e.writeDelimited(fields, false)
+	}
+	var n int64
+	row := make([]interface{}, len(names))
+	for {
+		err := src.next(row)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			bw.Flush()
+			return n, err
+		}
+		if opts.Format == JSONLines {
+			err = e.writeJSON(row)
+		} else {
+			e.writeDelimited(row, true)
+		}
+		if err != nil {
+			bw.Flush()
+			return n, err
+		}
+		n++
+	}
+	return n, bw.Flush()
+}
+
+type exporter struct {
+	w     *bufio.Writer
+	opts  ExportOptions
+	names []string
+	types []string
+}
+
+// format returns the text of a non-NULL value of column i.
+func (e *exporter) format(i int, v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		if e.types[i] == "TIMESTAMP" && e.opts.TimeLayout != "" {
+			if t, err := time.Parse(hiveTimestampLayout, v); err == nil {
+				return t.Format(e.opts.TimeLayout)
+			}
+		}
+		return v
+	case []byte:
+		return string(v)
+	case float64:
+		if e.types[i] == "FLOAT" {
+			return strconv.FormatFloat(v, 'g', -1, 32)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		layout := e.opts.TimeLayout
+		if layout == "" {
+			layout = hiveTimestampLayout
+		}
+		return v.Format(layout)
+	}
+	return fmt.Sprint(v)
+}
+
+// writeDelimited writes a CSV or TSV row. Write errors are reported by
+// the final Flush of the bufio.Writer.
+func (e *exporter) writeDelimited(row []interface{}, values bool) {
+	for i, v := range row {
+		if i > 0 {
+			e.w.WriteRune(e.opts.Delimiter)
+		}
+		if v == nil {
+			e.w.WriteString(e.opts.Null)
+			continue
+		}
+		s, _ := v.(string)
+		if values {
+			s = e.format(i, v)
+		}
+		e.writeField(s)
+	}
+	e.w.WriteByte('\n')
+}
+
+func (e *exporter) writeField(s string) {
+	switch e.opts.Quote {
+	case QuoteNone:
+		for _, r := range s {
+			switch r {
+			case '\\', e.opts.Delimiter:
+				e.w.WriteByte('\\')
+				e.w.WriteRune(r)
+			case '\n':
+				e.w.WriteString(`\n`)
+			case '\r':
+				e.w.WriteString(`\r`)
+			default:
+				e.w.WriteRune(r)
+			}
+		}
+		return
+	case QuoteMinimal:
+		if s != e.opts.Null && !strings.ContainsAny(s, "\"\r\n"+string(e.opts.Delimiter)) {
+			e.w.WriteString(s)
+			return
+		}
+	}
+	e.w.WriteByte('"')
+	e.w.WriteString(strings.ReplaceAll(s, `"`, `""`))
+	e.w.WriteByte('"')
+}
+
+func (e *exporter) writeJSON(row []interface{}) error {
+	e.w.WriteByte('{')
+	for i, v := range row {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		k, _ := json.Marshal(e.names[i])
+		e.w.Write(k)
+		e.w.WriteByte(':')
+		b, err := e.jsonValue(i, v)
+		if err != nil {
+			return err
+		}
+		e.w.Write(b)
+	}
+	e.w.WriteString("}\n")
+	return nil
+}
+
+func (e *exporter) jsonValue(i int, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return []byte("null"), nil
+	case string:
+		switch e.types[i] {
+		// DECIMAL values keep their exact digits rather than going through
+		// float64, complex values are already JSON.
+		case "DECIMAL", "ARRAY", "MAP", "STRUCT", "UNION":
+			if json.Valid([]byte(v)) {
+				return []byte(v), nil
+			}
+		}
+		return json.Marshal(e.format(i, v))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return json.Marshal(e.format(i, v))
+		}
+		return []byte(e.format(i, v)), nil
+	case time.Time:
+		return json.Marshal(e.format(i, v))
+	}
+	// Integers and booleans, and []byte as base64.
+	return json.Marshal(v)
+}
@@ -0,0 +1,105 @@
+package gohive
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+const exportQuery = "SELECT * FROM events"
+
+func newExportServer() *hivetest.Server {
+	srv := hivetest.NewServer("NOSASL")
+	srv.Handle(exportQuery, &hivetest.Result{
+		Columns: []hivetest.Column{
+			{Name: "id", Type: hiveserver2.TTypeId_BIGINT_TYPE},
+			{Name: "name", Type: hiveserver2.TTypeId_STRING_TYPE},
+			{Name: "score", Type: hiveserver2.TTypeId_FLOAT_TYPE},
+			{Name: "price", Type: hiveserver2.TTypeId_DECIMAL_TYPE},
+			{Name: "ts", Type: hiveserver2.TTypeId_TIMESTAMP_TYPE},
+			{Name: "tags", Type: hiveserver2.TTypeId_ARRAY_TYPE},
+			{Name: "ok", Type: hiveserver2.TTypeId_BOOLEAN_TYPE},
+		},
+		Rows: [][]interface{}{
+			{1, "plain", float64(float32(0.1)), "12345678901234567890.12", "2024-01-02 03:04:05.5", `["a","b"]`, true},
+			{2, "a,\"quoted\"\nname", nil, nil, nil, nil, nil},
+			{3, "", 2.5, "0.10", "2024-01-02 03:04:05", `[]`, false},
+		},
+	})
+	return srv
+}
+
+func TestExport(t *testing.T) {
+	srv := newExportServer()
+	defer srv.Close()
+	db, err := sql.Open("hive", srv.DSN())
+	assert.NoError(t, err)
+	defer db.Close()
+
+	for _, tc := range []struct {
+		name string
+		opts ExportOptions
+		want string
+	}{
+		{"CSV", ExportOptions{Header: true},
+			"id,name,score,price,ts,tags,ok\n" +
+				"1,plain,0.1,12345678901234567890.12,2024-01-02 03:04:05.5,\"[\"\"a\"\",\"\"b\"\"]\",true\n" +
+				"2,\"a,\"\"quoted\"\"\nname\",,,,,\n" +
+				"3,\"\",2.5,0.10,2024-01-02 03:04:05,[],false\n"},
+		{"TSV", ExportOptions{Format: TSV, Quote: QuoteNone, Null: `\N`, TimeLayout: "2006-01-02T15:04:05.000"},
+			"1\tplain\t0.1\t12345678901234567890.12\t2024-01-02T03:04:05.500\t[\"a\",\"b\"]\ttrue\n" +
+				"2\ta,\"quoted\"\\nname\t\\N\t\\N\t\\N\t\\N\t\\N\n" +
+				"3\t\t2.5\t0.10\t2024-01-02T03:04:05.000\t[]\tfalse\n"},
+		{"QuoteAll", ExportOptions{Delimiter: '|', Quote: QuoteAll, Null: "NULL"},
+			"\"1\"|\"plain\"|\"0.1\"|\"12345678901234567890.12\"|\"2024-01-02 03:04:05.5\"|\"[\"\"a\"\",\"\"b\"\"]\"|\"true\"\n" +
+				"\"2\"|\"a,\"\"quoted\"\"\nname\"|NULL|NULL|NULL|NULL|NULL\n" +
+				"\"3\"|\"\"|\"2.5\"|\"0.10\"|\"2024-01-02 03:04:05\"|\"[]\"|\"false\"\n"},
+		{"JSONLines", ExportOptions{Format: JSONLines, Header: true},
+			`{"id":1,"name":"plain","score":0.1,"price":12345678901234567890.12,"ts":"2024-01-02 03:04:05.5","tags":["a","b"],"ok":true}` + "\n" +
+				`{"id":2,"name":"a,\"quoted\"\nname","score":null,"price":null,"ts":null,"tags":null,"ok":null}` + "\n" +
+				`{"id":3,"name":"","score":2.5,"price":0.10,"ts":"2024-01-02 03:04:05","tags":[],"ok":false}` + "\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := assert.New(t)
+			rows, err := db.Query(exportQuery)
+			a.NoError(err)
+			defer rows.Close()
+			var b bytes.Buffer
+			n, err := Export(&b, rows, tc.opts)
+			a.NoError(err)
+			a.Equal(int64(3), n)
+			a.Equal(tc.want, b.String())
+		})
+	}
+}
+
+func TestExportDriverRows(t *testing.T) {
+	a := assert.New(t)
+	srv := newExportServer()
+	defer srv.Close()
+	db, err := sql.Open("hive", srv.DSN())
+	a.NoError(err)
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	a.NoError(err)
+	defer conn.Close()
+
+	var b bytes.Buffer
+	a.NoError(conn.Raw(func(dc interface{}) error {
+		rows, err := dc.(driver.QueryerContext).QueryContext(context.Background(), exportQuery, nil)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		n, err := ExportDriverRows(&b, rows, ExportOptions{Format: JSONLines})
+		a.Equal(int64(3), n)
+		return err
+	}))
+	a.Contains(b.String(), `"tags":["a","b"]`)
+}
@@ -0,0 +1,51 @@
+package hivearrow
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/stretchr/testify/assert"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+func TestAppendDoubleColumn(t *testing.T) {
+	a := assert.New(t)
+	col := &hiveserver2.TColumn{DoubleVal: &hiveserver2.TDoubleColumn{Values: []float64{0.5, 1.5}, Nulls: []byte{2}}}
+
+	f := array.NewFloat32Builder(memory.DefaultAllocator)
+	defer f.Release()
+	a.NoError(appendColumn(f, col))
+	arr := f.NewFloat32Array()
+	defer arr.Release()
+	a.Equal(float32(0.5), arr.Value(0))
+	a.True(arr.IsNull(1))
+
+	d := array.NewDecimal128Builder(memory.DefaultAllocator, &arrow.Decimal128Type{Precision: 10, Scale: 2})
+	defer d.Release()
+	a.Error(appendColumn(d, col))
+	a.Equal(0, d.Len())
+}
+
+func TestAppendMismatchedColumn(t *testing.T) {
+	a := assert.New(t)
+	// A TINYINT column that the server sends as i32Val.
+	col := &hiveserver2.TColumn{I32Val: &hiveserver2.TI32Column{Values: []int32{1, 2}, Nulls: []byte{}}}
+	b := array.NewInt8Builder(memory.DefaultAllocator)
+	defer b.Release()
+	a.EqualError(appendColumn(b, col), "cannot append INT values to a int8 column")
+	a.Equal(0, b.Len())
+
+	for _, col := range []*hiveserver2.TColumn{
+		{BoolVal: &hiveserver2.TBoolColumn{Values: []bool{true}}},
+		{ByteVal: &hiveserver2.TByteColumn{Values: []int8{1}}},
+		{I16Val: &hiveserver2.TI16Column{Values: []int16{1}}},
+		{I64Val: &hiveserver2.TI64Column{Values: []int64{1}}},
+		{BinaryVal: &hiveserver2.TBinaryColumn{Values: [][]byte{{1}}}},
+	} {
+		s := array.NewStringBuilder(memory.DefaultAllocator)
+		a.Error(appendColumn(s, col))
+		s.Release()
+	}
+}
@@ -0,0 +1,347 @@
+package hivearrow
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/decimal128"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+// hiveTimestampLayout is how hiveserver2 formats TIMESTAMP values.
+const hiveTimestampLayout = "2006-01-02 15:04:05.999999999"
+
+// columnar is implemented by the driver.Rows of gohive.
+type columnar interface {
+	Schema() (*hiveserver2.TTableSchema, error)
+	FetchColumns() ([]*hiveserver2.TColumn, error)
+}
+
+// Reader implements array.RecordReader. It yields a record for each batch
+// of rows that hiveserver2 returns.
+type Reader struct {
+	refs   int64
+	rows   columnar
+	mem    memory.Allocator
+	schema *arrow.Schema
+	rec    arrow.Record
+	err    error
+}
+
+var _ array.RecordReader = (*Reader)(nil)
+
+// NewReader returns a Reader over rows, which must be the driver.Rows
+// returned by the QueryContext method of a gohive connection, obtained
+// through database/sql.Conn.Raw. It waits for the query to finish.
+func NewReader(rows driver.Rows, mem memory.Allocator) (*Reader, error) {
+	c, ok := rows.(columnar)
+	if !ok {
+		return nil, fmt.Errorf("%T is not gohive rows", rows)
+	}
+	ts, err := c.Schema()
+	if err != nil {
+		return nil, err
+	}
+	schema, err := Schema(ts)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{refs: 1, rows: c, mem: mem, schema: schema}, nil
+}
+
+func (r *Reader) Retain() {
+	atomic.AddInt64(&r.refs, 1)
+}
+
+func (r *Reader) Release() {
+	if atomic.AddInt64(&r.refs, -1) == 0 && r.rec != nil {
+		r.rec.Release()
+		r.rec = nil
+	}
+}
+
+func (r *Reader) Schema() *arrow.Schema {
+	return r.schema
+}
+
+// Next fetches the next batch. The previous record is released.
+func (r *Reader) Next() bool {
+	if r.rec != nil {
+		r.rec.Release()
+		r.rec = nil
+	}
+	if r.err != nil {
+		return false
+	}
+	cols, err := r.rows.FetchColumns()
+	if err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+		return false
+	}
+	r.rec, r.err = r.newRecord(cols)
+	return r.err == nil
+}
+
+// Record returns the current record, valid until the next call to Next.
+func (r *Reader) Record() arrow.Record {
+	return r.rec
+}
+
+// Err returns the error that stopped Next, if any.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+func (r *Reader) newRecord(cols []*hiveserver2.TColumn) (arrow.Record, error) {
+	b := array.NewRecordBuilder(r.mem, r.schema)
+	defer b.Release()
+	if len(cols) != len(r.schema.Fields()) {
+		return nil, fmt.Errorf("got %d columns, expected %d", len(cols), len(r.schema.Fields()))
+	}
+	for i, col := range cols {
+		if err := appendColumn(b.Field(i), col); err != nil {
+			return nil, fmt.Errorf("column %s: %v", r.schema.Field(i).Name, err)
+		}
+	}
+	return b.NewRecord(), nil
+}
+
+// Query runs query on conn and calls fn with each record batch. Records
+// are released when fn returns, call Retain to keep them longer.
+func Query(ctx context.Context, conn *sql.Conn, query string, mem memory.Allocator, fn func(arrow.Record) error) error {
+	return conn.Raw(func(dc interface{}) error {
+		q, ok := dc.(driver.QueryerContext)
+		if !ok {
+			return fmt.Errorf("%T is not a gohive connection", dc)
+		}
+		rows, err := q.QueryContext(ctx, query, nil)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		r, err := NewReader(rows, mem)
+		if err != nil {
+			return err
+		}
+		defer r.Release()
+		for r.Next() {
+			if err := fn(r.Record()); err != nil {
+				return err
+			}
+		}
+		return r.Err()
+	})
+}
+
+// valid converts a TColumn nulls bitmap to the validity slice of Arrow
+// builders. nil means all values are valid.
+func valid(nulls []byte, n int) []bool {
+	if !hasNulls(nulls) {
+		return nil
+	}
+	v := make([]bool, n)
+	for j := range v {
+		v[j] = j/8 >= len(nulls) || nulls[j/8]&(1<<(uint(j)%8)) == 0
+	}
+	return v
+}
+
+func hasNulls(nulls []byte) bool {
+	for _, b := range nulls {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func appendColumn(b array.Builder, col *hiveserver2.TColumn) error {
+	switch {
+	case col.IsSetBoolVal():
+		v := col.BoolVal
+		bb, ok := b.(*array.BooleanBuilder)
+		if !ok {
+			return mismatch("BOOLEAN", b)
+		}
+		bb.AppendValues(v.Values, valid(v.Nulls, len(v.Values)))
+	case col.IsSetByteVal():
+		v := col.ByteVal
+		bb, ok := b.(*array.Int8Builder)
+		if !ok {
+			return mismatch("TINYINT", b)
+		}
+		bb.AppendValues(v.Values, valid(v.Nulls, len(v.Values)))
+	case col.IsSetI16Val():
+		v := col.I16Val
+		bb, ok := b.(*array.Int16Builder)
+		if !ok {
+			return mismatch("SMALLINT", b)
+		}
+		bb.AppendValues(v.Values, valid(v.Nulls, len(v.Values)))
+	case col.IsSetI32Val():
+		v := col.I32Val
+		bb, ok := b.(*array.Int32Builder)
+		if !ok {
+			return mismatch("INT", b)
+		}
+		bb.AppendValues(v.Values, valid(v.Nulls, len(v.Values)))
+	case col.IsSetI64Val():
+		v := col.I64Val
+		bb, ok := b.(*array.Int64Builder)
+		if !ok {
+			return mismatch("BIGINT", b)
+		}
+		bb.AppendValues(v.Values, valid(v.Nulls, len(v.Values)))
+	case col.IsSetDoubleVal():
+		v := col.DoubleVal
+		ok := valid(v.Nulls, len(v.Values))
+		switch b := b.(type) {
+		case *array.Float64Builder:
+			b.AppendValues(v.Values, ok)
+		case *array.Float32Builder:
+			f := make([]float32, len(v.Values))
+			for j, x := range v.Values {
+				f[j] = float32(x)
+			}
+			b.AppendValues(f, ok)
+		default:
+			return mismatch("DOUBLE", b)
+		}
+	case col.IsSetBinaryVal():
+		v := col.BinaryVal
+		bb, ok := b.(*array.BinaryBuilder)
+		if !ok {
+			return mismatch("BINARY", b)
+		}
+		bb.AppendValues(v.Values, valid(v.Nulls, len(v.Values)))
+	case col.IsSetStringVal():
+		v := col.StringVal
+		return appendStrings(b, v.Values, valid(v.Nulls, len(v.Values)))
+	default:
+		return errors.New("empty TColumn")
+	}
+	return nil
+}
+
+// mismatch is the error of a TColumn whose kind does not fit the column type
+// of the schema.
+func mismatch(kind string, b array.Builder) error {
+	return fmt.Errorf("cannot append %s values to a %s column", kind, b.Type())
+}
+
+// appendStrings appends string values, parsing them into b's type.
+func appendStrings(b array.Builder, values []string, ok []bool) error {
+	if sb, isString := b.(*array.StringBuilder); isString {
+		sb.AppendValues(values, ok)
+		return nil
+	}
+	for j, s := range values {
+		if ok != nil && !ok[j] {
+			b.AppendNull()
+			continue
+		}
+		if err := appendString(b, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendString(b array.Builder, s string) error {
+	switch b := b.(type) {
+	case *array.Date32Builder:
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return err
+		}
+		b.Append(arrow.Date32FromTime(t))
+	case *array.TimestampBuilder:
+		t, err := time.Parse(hiveTimestampLayout, s)
+		if err != nil {
+			return err
+		}
+		b.Append(arrow.Timestamp(t.UnixNano()))
+	case *array.Decimal128Builder:
+		dt := b.Type().(*arrow.Decimal128Type)
+		n, err := decimal128.FromString(s, dt.Precision, dt.Scale)
+		if err != nil {
+			return err
+		}
+		b.Append(n)
+	case *array.NullBuilder:
+		b.AppendNull()
+	default:
+		// Nested types, as the JSON text hiveserver2 returns.
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader([]byte(s)))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		j, err := json.Marshal([]interface{}{toArrowJSON(v, b.Type())})
+		if err != nil {
+			return err
+		}
+		return b.UnmarshalJSON(j)
+	}
+	return nil
+}
+
+// toArrowJSON converts a value decoded from the JSON text of a Hive value
+// to the JSON representation Arrow builders expect for dt.
+func toArrowJSON(v interface{}, dt arrow.DataType) interface{} {
+	switch dt := dt.(type) {
+	case *arrow.ListType:
+		if l, ok := v.([]interface{}); ok {
+			for i := range l {
+				l[i] = toArrowJSON(l[i], dt.Elem())
+			}
+		}
+	case *arrow.MapType:
+		// Hive writes maps as objects, Arrow as lists of key-value pairs.
+		if m, ok := v.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(m))
+			for k := range m {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			pairs := make([]interface{}, 0, len(m))
+			for _, k := range keys {
+				e := m[k]
+				var key interface{} = k
+				switch dt.KeyType().ID() {
+				case arrow.INT8, arrow.INT16, arrow.INT32, arrow.INT64, arrow.FLOAT32, arrow.FLOAT64:
+					key = json.Number(k)
+				case arrow.BOOL:
+					key = k == "true"
+				}
+				pairs = append(pairs, map[string]interface{}{
+					"key":   key,
+					"value": toArrowJSON(e, dt.ItemType()),
+				})
+			}
+			return pairs
+		}
+	case *arrow.StructType:
+		if m, ok := v.(map[string]interface{}); ok {
+			for _, f := range dt.Fields() {
+				m[f.Name] = toArrowJSON(m[f.Name], f.Type)
+			}
+		}
+	}
+	return v
+}
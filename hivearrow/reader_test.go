@@ -0,0 +1,137 @@
+package hivearrow_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/stretchr/testify/assert"
+	_ "sqlflow.org/gohive"
+	"sqlflow.org/gohive/hivearrow"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func primitive(t hiveserver2.TTypeId) *hiveserver2.TTypeEntry {
+	return &hiveserver2.TTypeEntry{PrimitiveEntry: &hiveserver2.TPrimitiveTypeEntry{Type: t}}
+}
+
+func decimal(precision, scale int32) *hiveserver2.TTypeDesc {
+	e := primitive(hiveserver2.TTypeId_DECIMAL_TYPE)
+	e.PrimitiveEntry.TypeQualifiers = &hiveserver2.TTypeQualifiers{Qualifiers: map[string]*hiveserver2.TTypeQualifierValue{
+		hiveserver2.PRECISION: {I32Value: &precision},
+		hiveserver2.SCALE:     {I32Value: &scale},
+	}}
+	return &hiveserver2.TTypeDesc{Types: []*hiveserver2.TTypeEntry{e}}
+}
+
+// valueJSON returns the JSON encoding of arr[j].
+func valueJSON(arr arrow.Array, j int) string {
+	s := array.NewSlice(arr, int64(j), int64(j+1))
+	defer s.Release()
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b[1 : len(b)-1])
+}
+
+func TestQuery(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("SELECT * FROM features", &hivetest.Result{
+		Columns: []hivetest.Column{
+			{Name: "id", Type: hiveserver2.TTypeId_BIGINT_TYPE},
+			{Name: "name", Type: hiveserver2.TTypeId_STRING_TYPE},
+			{Name: "score", Type: hiveserver2.TTypeId_FLOAT_TYPE},
+			{Name: "price", Type: hiveserver2.TTypeId_DECIMAL_TYPE, TypeDesc: decimal(20, 2)},
+			{Name: "ts", Type: hiveserver2.TTypeId_TIMESTAMP_TYPE},
+			{Name: "day", Type: hiveserver2.TTypeId_DATE_TYPE},
+			{Name: "tags", Type: hiveserver2.TTypeId_ARRAY_TYPE, TypeDesc: &hiveserver2.TTypeDesc{Types: []*hiveserver2.TTypeEntry{
+				{ArrayEntry: &hiveserver2.TArrayTypeEntry{ObjectTypePtr: 1}},
+				primitive(hiveserver2.TTypeId_STRING_TYPE),
+			}}},
+			{Name: "counts", Type: hiveserver2.TTypeId_MAP_TYPE, TypeDesc: &hiveserver2.TTypeDesc{Types: []*hiveserver2.TTypeEntry{
+				{MapEntry: &hiveserver2.TMapTypeEntry{KeyTypePtr: 1, ValueTypePtr: 2}},
+				primitive(hiveserver2.TTypeId_INT_TYPE),
+				primitive(hiveserver2.TTypeId_BIGINT_TYPE),
+			}}},
+			{Name: "raw", Type: hiveserver2.TTypeId_STRUCT_TYPE},
+		},
+		Rows: [][]interface{}{
+			{1, "a", 0.5, "12.34", "2024-01-02 03:04:05.123", "2024-01-02", `["x","y"]`, `{"1":10,"2":20}`, `{"f":1}`},
+			{2, nil, nil, nil, nil, nil, nil, nil, nil},
+			{3, "c", 1.5, "-0.01", "1970-01-01 00:00:00", "1970-01-01", `[]`, `{}`, `{"f":3}`},
+		},
+	})
+	db, err := sql.Open("hive", srv.DSN()+"&batch=2")
+	a.NoError(err)
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	a.NoError(err)
+	defer conn.Close()
+
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	var lens []int64
+	var ids []int64
+	var names, prices, raws []string
+	var ts []arrow.Timestamp
+	var tags, counts []string
+	err = hivearrow.Query(context.Background(), conn, "SELECT * FROM features", mem, func(rec arrow.Record) error {
+		lens = append(lens, rec.NumRows())
+		a.Equal("hive.type", rec.Schema().Field(8).Metadata.Keys()[0])
+		a.Equal(arrow.DECIMAL128, rec.Column(3).DataType().ID())
+		ids = append(ids, rec.Column(0).(*array.Int64).Int64Values()...)
+		for j := 0; j < int(rec.NumRows()); j++ {
+			names = append(names, valueJSON(rec.Column(1), j))
+			if price := rec.Column(3).(*array.Decimal128); price.IsValid(j) {
+				prices = append(prices, price.Value(j).ToString(2))
+			} else {
+				prices = append(prices, "NULL")
+			}
+			ts = append(ts, rec.Column(4).(*array.Timestamp).Value(j))
+			tags = append(tags, valueJSON(rec.Column(6), j))
+			counts = append(counts, valueJSON(rec.Column(7), j))
+			raws = append(raws, valueJSON(rec.Column(8), j))
+		}
+		return nil
+	})
+	a.NoError(err)
+	a.Equal([]int64{2, 1}, lens)
+	a.Equal([]int64{1, 2, 3}, ids)
+	a.Equal([]string{`"a"`, "null", `"c"`}, names)
+	a.Equal([]string{"12.34", "NULL", "-0.01"}, prices)
+	a.Equal(arrow.Timestamp(1704164645123000000), ts[0])
+	a.Equal(arrow.Timestamp(0), ts[2])
+	a.Equal([]string{`["x","y"]`, "null", "[]"}, tags)
+	a.Equal([]string{`[{"key":1,"value":10},{"key":2,"value":20}]`, "null", "[]"}, counts)
+	a.Equal([]string{`"{\"f\":1}"`, "null", `"{\"f\":3}"`}, raws)
+}
+
+func TestSchema(t *testing.T) {
+	a := assert.New(t)
+	s, err := hivearrow.Schema(&hiveserver2.TTableSchema{Columns: []*hiveserver2.TColumnDesc{
+		{ColumnName: "s", TypeDesc: &hiveserver2.TTypeDesc{Types: []*hiveserver2.TTypeEntry{
+			{StructEntry: &hiveserver2.TStructTypeEntry{NameToTypePtr: map[string]hiveserver2.TTypeEntryPtr{"b": 1, "a": 2}}},
+			primitive(hiveserver2.TTypeId_BOOLEAN_TYPE),
+			primitive(hiveserver2.TTypeId_DOUBLE_TYPE),
+		}}},
+		{ColumnName: "d", TypeDesc: &hiveserver2.TTypeDesc{Types: []*hiveserver2.TTypeEntry{primitive(hiveserver2.TTypeId_DECIMAL_TYPE)}}},
+	}})
+	a.NoError(err)
+	a.Equal("struct<a: float64, b: bool>", s.Field(0).Type.String())
+	a.Equal(&arrow.Decimal128Type{Precision: 10, Scale: 0}, s.Field(1).Type)
+
+	_, err = hivearrow.Schema(&hiveserver2.TTableSchema{Columns: []*hiveserver2.TColumnDesc{
+		{ColumnName: "bad", TypeDesc: &hiveserver2.TTypeDesc{Types: []*hiveserver2.TTypeEntry{
+			{ArrayEntry: &hiveserver2.TArrayTypeEntry{ObjectTypePtr: 5}},
+		}}},
+	}})
+	a.ErrorContains(err, "column bad")
+}
@@ -0,0 +1,141 @@
+// Package hivearrow converts gohive query results to Apache Arrow record
+// batches, directly from the column-oriented TRowSet of each FetchResults
+// response.
+package hivearrow
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+// HiveTypeKey is the field metadata key holding the Hive type of columns
+// that are represented as strings, e.g. ARRAY_TYPE for arrays whose element
+// type hiveserver2 did not describe.
+const HiveTypeKey = "hive.type"
+
+// Hive DECIMAL defaults to DECIMAL(10, 0).
+const (
+	defaultPrecision = 10
+	defaultScale     = 0
+)
+
+// Schema maps a hiveserver2 result schema to an Arrow schema.
+//
+// Primitive types map to the corresponding Arrow types. DATE, TIMESTAMP
+// and DECIMAL, which hiveserver2 sends as strings, are parsed into Date32,
+// nanosecond Timestamp and Decimal128. ARRAY, MAP and STRUCT map to List,
+// Map and Struct if the type descriptor describes their element types;
+// struct fields are then sorted by name, as the descriptor does not keep
+// their order. Hive itself only sends the top-level type, so these columns
+// and other types are kept as strings holding the JSON text hiveserver2
+// returns, tagged with HiveTypeKey metadata.
+func Schema(ts *hiveserver2.TTableSchema) (*arrow.Schema, error) {
+	fields := make([]arrow.Field, len(ts.GetColumns()))
+	for i, col := range ts.GetColumns() {
+		if col.TypeDesc == nil || len(col.TypeDesc.Types) == 0 {
+			return nil, fmt.Errorf("column %s has no type", col.ColumnName)
+		}
+		dt, err := arrowType(col.TypeDesc.Types, 0)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", col.ColumnName, err)
+		}
+		fields[i] = arrow.Field{Name: col.ColumnName, Type: dt, Nullable: true}
+		if e := col.TypeDesc.Types[0].PrimitiveEntry; e != nil && dt.ID() == arrow.STRING && !isStringType(e.Type) {
+			fields[i].Metadata = arrow.NewMetadata([]string{HiveTypeKey}, []string{e.Type.String()})
+		}
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+func isStringType(t hiveserver2.TTypeId) bool {
+	return t == hiveserver2.TTypeId_STRING_TYPE || t == hiveserver2.TTypeId_VARCHAR_TYPE ||
+		t == hiveserver2.TTypeId_CHAR_TYPE
+}
+
+// arrowType maps the type entry types[i] to an Arrow type.
+func arrowType(types []*hiveserver2.TTypeEntry, i hiveserver2.TTypeEntryPtr) (arrow.DataType, error) {
+	if i < 0 || int(i) >= len(types) {
+		return nil, fmt.Errorf("type entry %d out of range", i)
+	}
+	e := types[i]
+	switch {
+	case e.ArrayEntry != nil:
+		elem, err := arrowType(types, e.ArrayEntry.ObjectTypePtr)
+		if err != nil {
+			return nil, err
+		}
+		return arrow.ListOf(elem), nil
+	case e.MapEntry != nil:
+		key, err := arrowType(types, e.MapEntry.KeyTypePtr)
+		if err != nil {
+			return nil, err
+		}
+		value, err := arrowType(types, e.MapEntry.ValueTypePtr)
+		if err != nil {
+			return nil, err
+		}
+		return arrow.MapOf(key, value), nil
+	case e.StructEntry != nil:
+		names := make([]string, 0, len(e.StructEntry.NameToTypePtr))
+		for name := range e.StructEntry.NameToTypePtr {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fields := make([]arrow.Field, len(names))
+		for j, name := range names {
+			dt, err := arrowType(types, e.StructEntry.NameToTypePtr[name])
+			if err != nil {
+				return nil, err
+			}
+			fields[j] = arrow.Field{Name: name, Type: dt, Nullable: true}
+		}
+		return arrow.StructOf(fields...), nil
+	case e.PrimitiveEntry != nil:
+		return primitiveType(e.PrimitiveEntry), nil
+	}
+	// Unions and user defined types.
+	return arrow.BinaryTypes.String, nil
+}
+
+func primitiveType(e *hiveserver2.TPrimitiveTypeEntry) arrow.DataType {
+	switch e.Type {
+	case hiveserver2.TTypeId_BOOLEAN_TYPE:
+		return arrow.FixedWidthTypes.Boolean
+	case hiveserver2.TTypeId_TINYINT_TYPE:
+		return arrow.PrimitiveTypes.Int8
+	case hiveserver2.TTypeId_SMALLINT_TYPE:
+		return arrow.PrimitiveTypes.Int16
+	case hiveserver2.TTypeId_INT_TYPE:
+		return arrow.PrimitiveTypes.Int32
+	case hiveserver2.TTypeId_BIGINT_TYPE:
+		return arrow.PrimitiveTypes.Int64
+	case hiveserver2.TTypeId_FLOAT_TYPE:
+		return arrow.PrimitiveTypes.Float32
+	case hiveserver2.TTypeId_DOUBLE_TYPE:
+		return arrow.PrimitiveTypes.Float64
+	case hiveserver2.TTypeId_BINARY_TYPE:
+		return arrow.BinaryTypes.Binary
+	case hiveserver2.TTypeId_DATE_TYPE:
+		return arrow.FixedWidthTypes.Date32
+	case hiveserver2.TTypeId_TIMESTAMP_TYPE:
+		return arrow.FixedWidthTypes.Timestamp_ns
+	case hiveserver2.TTypeId_NULL_TYPE:
+		return arrow.Null
+	case hiveserver2.TTypeId_DECIMAL_TYPE:
+		precision, scale := int32(defaultPrecision), int32(defaultScale)
+		if e.TypeQualifiers != nil {
+			q := e.TypeQualifiers.Qualifiers
+			if v, found := q[hiveserver2.PRECISION]; found && v.I32Value != nil {
+				precision = *v.I32Value
+			}
+			if v, found := q[hiveserver2.SCALE]; found && v.I32Value != nil {
+				scale = *v.I32Value
+			}
+		}
+		return &arrow.Decimal128Type{Precision: precision, Scale: scale}
+	}
+	return arrow.BinaryTypes.String
+}
@@ -0,0 +1,254 @@
+package hiveparquet
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/decimal128"
+	"sqlflow.org/gohive"
+)
+
+// hiveTimestampLayout is how Hive formats TIMESTAMP values as text.
+const hiveTimestampLayout = "2006-01-02 15:04:05.999999999"
+
+// StageFormat writes the staging files of gohive.Load as Parquet, for
+// tables STORED AS PARQUET. Schema must list the columns of the table in
+// order, e.g. as returned by hivearrow.Schema for the result schema of
+// SELECT * FROM table LIMIT 0. Hive matches Parquet columns by name, so the
+// "table." prefix that hiveserver2 adds to such column names is stripped.
+// Values are converted to the Arrow type of their column: Go integers and
+// floats to numeric types, strings and time.Time to TIMESTAMP and DATE, and
+// strings and floats to DECIMAL. Nested types are not supported.
+//
+// Hive reads Parquet TIMESTAMP columns stored as INT96 only, so set
+// Options.Int96Timestamps if the table has any.
+type StageFormat struct {
+	Schema  *arrow.Schema
+	Options Options
+}
+
+// Ext implements gohive.StageFormat.
+func (f StageFormat) Ext() string {
+	return ".parquet"
+}
+
+// Create implements gohive.StageFormat. Options.MaxFileSize is ignored, as
+// a single file is loaded.
+func (f StageFormat) Create(path string) (gohive.RowWriter, error) {
+	opts := f.Options
+	opts.MaxFileSize = 0
+	schema := unqualified(f.Schema)
+	w := NewWriter(path, schema, opts)
+	maxRows := opts.RowGroupRows
+	if maxRows <= 0 {
+		maxRows = DefaultRowGroupRows
+	}
+	return &stageWriter{w: w, b: array.NewRecordBuilder(w.allocator(), schema), maxRows: maxRows}, nil
+}
+
+// unqualified returns schema with the table name removed from qualified
+// field names like "t.id".
+func unqualified(schema *arrow.Schema) *arrow.Schema {
+	fields := make([]arrow.Field, len(schema.Fields()))
+	for i, f := range schema.Fields() {
+		f.Name = f.Name[strings.LastIndexByte(f.Name, '.')+1:]
+		fields[i] = f
+	}
+	md := schema.Metadata()
+	return arrow.NewSchema(fields, &md)
+}
+
+// stageWriter buffers rows in a RecordBuilder and writes a record per row
+// group.
+type stageWriter struct {
+	w       *Writer
+	b       *array.RecordBuilder
+	rows    int64
+	maxRows int64
+}
+
+// WriteRow converts all values of row before appending any, so that a row
+// that fails is not written at all.
+func (s *stageWriter) WriteRow(row []interface{}) error {
+	fields := s.b.Fields()
+	if len(row) != len(fields) {
+		return fmt.Errorf("row has %d values, the schema has %d fields", len(row), len(fields))
+	}
+	appends := make([]func(), len(row))
+	for i, v := range row {
+		var err error
+		if appends[i], err = appender(fields[i], v); err != nil {
+			return fmt.Errorf("column %s: %v", s.b.Schema().Field(i).Name, err)
+		}
+	}
+	for _, f := range appends {
+		f()
+	}
+	s.rows++
+	if s.rows == s.maxRows {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *stageWriter) flush() error {
+	rec := s.b.NewRecord()
+	defer rec.Release()
+	s.rows = 0
+	return s.w.Write(rec)
+}
+
+func (s *stageWriter) Close() error {
+	defer s.b.Release()
+	var err error
+	if s.rows > 0 {
+		err = s.flush()
+	}
+	if err != nil {
+		s.w.Abort()
+		return err
+	}
+	return s.w.Close()
+}
+
+// appender converts v to the type of b and returns a function that appends
+// it, or an error if v cannot be converted.
+func appender(b array.Builder, v interface{}) (func(), error) {
+	if v == nil {
+		return b.AppendNull, nil
+	}
+	switch b := b.(type) {
+	case *array.BooleanBuilder:
+		if x, ok := v.(bool); ok {
+			return func() { b.Append(x) }, nil
+		}
+	case *array.Int8Builder:
+		if n, ok := toInt64(v); ok && int64(int8(n)) == n {
+			return func() { b.Append(int8(n)) }, nil
+		}
+	case *array.Int16Builder:
+		if n, ok := toInt64(v); ok && int64(int16(n)) == n {
+			return func() { b.Append(int16(n)) }, nil
+		}
+	case *array.Int32Builder:
+		if n, ok := toInt64(v); ok && int64(int32(n)) == n {
+			return func() { b.Append(int32(n)) }, nil
+		}
+	case *array.Int64Builder:
+		if n, ok := toInt64(v); ok {
+			return func() { b.Append(n) }, nil
+		}
+	case *array.Float32Builder:
+		if f, ok := toFloat64(v); ok {
+			return func() { b.Append(float32(f)) }, nil
+		}
+	case *array.Float64Builder:
+		if f, ok := toFloat64(v); ok {
+			return func() { b.Append(f) }, nil
+		}
+	case *array.StringBuilder:
+		switch x := v.(type) {
+		case string:
+			return func() { b.Append(x) }, nil
+		case []byte:
+			return func() { b.Append(string(x)) }, nil
+		}
+	case *array.BinaryBuilder:
+		switch x := v.(type) {
+		case []byte:
+			return func() { b.Append(x) }, nil
+		case string:
+			return func() { b.AppendString(x) }, nil
+		}
+	case *array.TimestampBuilder:
+		t, err := toTime(v, hiveTimestampLayout)
+		if err != nil {
+			return nil, err
+		}
+		var ts arrow.Timestamp
+		switch b.Type().(*arrow.TimestampType).Unit {
+		case arrow.Second:
+			ts = arrow.Timestamp(t.Unix())
+		case arrow.Millisecond:
+			ts = arrow.Timestamp(t.UnixMilli())
+		case arrow.Microsecond:
+			ts = arrow.Timestamp(t.UnixMicro())
+		default:
+			ts = arrow.Timestamp(t.UnixNano())
+		}
+		return func() { b.Append(ts) }, nil
+	case *array.Date32Builder:
+		t, err := toTime(v, "2006-01-02")
+		if err != nil {
+			return nil, err
+		}
+		return func() { b.Append(arrow.Date32FromTime(t)) }, nil
+	case *array.Decimal128Builder:
+		dt := b.Type().(*arrow.Decimal128Type)
+		var n decimal128.Num
+		var err error
+		switch x := v.(type) {
+		case string:
+			n, err = decimal128.FromString(x, dt.Precision, dt.Scale)
+		case []byte:
+			n, err = decimal128.FromString(string(x), dt.Precision, dt.Scale)
+		default:
+			f, ok := toFloat64(v)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert %T to %s", v, b.Type())
+			}
+			n, err = decimal128.FromFloat64(f, dt.Precision, dt.Scale)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return func() { b.Append(n) }, nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to %s", v, b.Type())
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	n, ok := toInt64(v)
+	return float64(n), ok
+}
+
+func toTime(v interface{}, layout string) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(layout, v)
+	}
+	return time.Time{}, fmt.Errorf("cannot convert %T to a time", v)
+}
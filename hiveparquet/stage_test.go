@@ -0,0 +1,64 @@
+package hiveparquet_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/stretchr/testify/assert"
+	"sqlflow.org/gohive/hiveparquet"
+)
+
+func TestStageFormat(t *testing.T) {
+	a := assert.New(t)
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "t.id", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+		{Name: "score", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+		{Name: "price", Type: &arrow.Decimal128Type{Precision: 10, Scale: 2}, Nullable: true},
+		{Name: "ts", Type: arrow.FixedWidthTypes.Timestamp_ns, Nullable: true},
+		{Name: "day", Type: arrow.FixedWidthTypes.Date32, Nullable: true},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, nil)
+	f := hiveparquet.StageFormat{Schema: schema, Options: hiveparquet.Options{RowGroupRows: 2}}
+	a.Equal(".parquet", f.Ext())
+	path := filepath.Join(t.TempDir(), "stage.parquet")
+	w, err := f.Create(path)
+	a.NoError(err)
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	a.NoError(w.WriteRow([]interface{}{1, 0.5, "12.34", ts, "2024-01-02", "a"}))
+	a.NoError(w.WriteRow([]interface{}{int64(2), 3, 1.5, "2024-01-02 03:04:05", ts, nil}))
+	a.NoError(w.WriteRow([]interface{}{nil, nil, nil, nil, nil, "c"}))
+	a.Error(w.WriteRow([]interface{}{1 << 40, nil, nil, nil, nil, nil}))
+	a.Error(w.WriteRow([]interface{}{1}))
+	a.NoError(w.Close())
+
+	r, tbl := readFile(t, path)
+	a.Equal(2, r.NumRowGroups())
+	a.Equal("id", tbl.Schema().Field(0).Name)
+	a.Equal("t.id", schema.Field(0).Name)
+	a.EqualValues(3, tbl.NumRows())
+	col := func(i int) arrow.Array { return tbl.Column(i).Data().Chunk(0) }
+	a.Equal(int32(2), col(0).(*array.Int32).Value(1))
+	a.Equal(3.0, col(1).(*array.Float64).Value(1))
+	a.Equal("12.34", col(2).(*array.Decimal128).Value(0).ToString(2))
+	a.Equal("1.50", col(2).(*array.Decimal128).Value(1).ToString(2))
+	a.Equal(arrow.Timestamp(ts.UnixNano()), col(3).(*array.Timestamp).Value(1))
+	a.Equal(arrow.Date32FromTime(ts), col(4).(*array.Date32).Value(0))
+	a.Equal("a", col(5).(*array.String).Value(0))
+	a.True(col(5).IsNull(1))
+	a.True(col(0).IsNull(2))
+	a.Equal(3, col(5).Len())
+	a.Equal("c", col(5).(*array.String).Value(2))
+}
+
+func TestStageFormatEmpty(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{{Name: "id", Type: arrow.PrimitiveTypes.Int64, Nullable: true}}, nil)
+	path := filepath.Join(t.TempDir(), "stage.parquet")
+	w, err := hiveparquet.StageFormat{Schema: schema}.Create(path)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	_, tbl := readFile(t, path)
+	assert.EqualValues(t, 0, tbl.NumRows())
+}
@@ -0,0 +1,286 @@
+// Package hiveparquet streams gohive query results into Parquet files. The
+// Parquet schema is derived from the hiveserver2 result schema through
+// hivearrow.Schema.
+package hiveparquet
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/apache/arrow/go/v11/parquet"
+	"github.com/apache/arrow/go/v11/parquet/compress"
+	"github.com/apache/arrow/go/v11/parquet/file"
+	"github.com/apache/arrow/go/v11/parquet/pqarrow"
+	"sqlflow.org/gohive/hivearrow"
+)
+
+// DefaultRowGroupRows is the number of rows per row group by default.
+const DefaultRowGroupRows = 128 * 1024
+
+// Options controls how Parquet files are written.
+type Options struct {
+	// RowGroupRows is the maximum number of rows per row group,
+	// DefaultRowGroupRows if zero.
+	RowGroupRows int64
+	// Compression is the codec of all columns, e.g. compress.Codecs.Snappy.
+	// The zero value leaves data uncompressed.
+	Compression compress.Compression
+	// MaxFileSize makes the writer start a new file once the current one
+	// reaches this many bytes, if greater than zero. Files may exceed it by
+	// the size of the last batch written.
+	MaxFileSize int64
+	// Int96Timestamps writes timestamps as INT96, which older Hive and
+	// Impala releases require.
+	Int96Timestamps bool
+	// Allocator allocates Arrow buffers, memory.DefaultAllocator if nil.
+	Allocator memory.Allocator
+}
+
+// Write runs query on conn and writes its result to Parquet files, and
+// returns the names of the files written. Without MaxFileSize the result is
+// written to path. Otherwise files are numbered, e.g. path out.parquet gives
+// out-00000.parquet, out-00001.parquet and so on. An empty result gives a
+// file without rows. Files are removed if Write fails.
+func Write(ctx context.Context, conn *sql.Conn, query, path string, opts Options) ([]string, error) {
+	if opts.Allocator == nil {
+		opts.Allocator = memory.DefaultAllocator
+	}
+	var files []string
+	err := conn.Raw(func(dc interface{}) error {
+		q, ok := dc.(driver.QueryerContext)
+		if !ok {
+			return fmt.Errorf("%T is not a gohive connection", dc)
+		}
+		rows, err := q.QueryContext(ctx, query, nil)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		r, err := hivearrow.NewReader(rows, opts.Allocator)
+		if err != nil {
+			return err
+		}
+		defer r.Release()
+		files, err = WriteRecords(r, path, opts)
+		return err
+	})
+	return files, err
+}
+
+// WriteRecords writes the records of r to Parquet files like Write. If r
+// has an Err method, like hivearrow.Reader, the error stopping it is
+// returned.
+func WriteRecords(r array.RecordReader, path string, opts Options) ([]string, error) {
+	w := NewWriter(path, r.Schema(), opts)
+	for r.Next() {
+		if err := w.Write(r.Record()); err != nil {
+			w.Abort()
+			return nil, err
+		}
+	}
+	var err error
+	if e, ok := r.(interface{ Err() error }); ok {
+		err = e.Err()
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		w.Abort()
+		return nil, err
+	}
+	return w.Files(), nil
+}
+
+// Writer writes Arrow records to Parquet files, splitting them into row
+// groups and rolling over to new files as configured by Options.
+type Writer struct {
+	path   string
+	schema *arrow.Schema
+	opts   Options
+	files  []string
+
+	file    *os.File
+	counter *countingWriter
+	fw      *pqarrow.FileWriter
+	// rows is the number of rows in the current row group.
+	rows int64
+}
+
+// NewWriter returns a Writer of records with the given schema, to files
+// named after path as in Write. The first file is created by the first
+// call to Write.
+func NewWriter(path string, schema *arrow.Schema, opts Options) *Writer {
+	return &Writer{path: path, schema: schema, opts: opts}
+}
+
+// Write appends the rows of rec, whose schema must be the schema of w.
+func (w *Writer) Write(rec arrow.Record) error {
+	maxRows := w.opts.RowGroupRows
+	if maxRows <= 0 {
+		maxRows = DefaultRowGroupRows
+	}
+	for off := int64(0); off < rec.NumRows(); {
+		if w.fw == nil {
+			if err := w.open(); err != nil {
+				return err
+			}
+		}
+		if w.rows == maxRows {
+			w.fw.NewBufferedRowGroup()
+			w.rows = 0
+		}
+		n := rec.NumRows() - off
+		if n > maxRows-w.rows {
+			n = maxRows - w.rows
+		}
+		chunk := rec.NewSlice(off, off+n)
+		err := w.fw.WriteBuffered(chunk)
+		chunk.Release()
+		if err != nil {
+			return err
+		}
+		w.rows += n
+		off += n
+
+		if w.opts.MaxFileSize > 0 && w.counter.n+w.fw.RowGroupTotalCompressedBytes() >= w.opts.MaxFileSize {
+			if err := w.closeFile(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close completes the current file. If no rows were written, it writes a
+// file without rows.
+func (w *Writer) Close() error {
+	if len(w.files) == 0 {
+		return w.writeEmpty()
+	}
+	return w.closeFile()
+}
+
+// Files returns the names of the files written so far.
+func (w *Writer) Files() []string {
+	return w.files
+}
+
+// Abort closes and removes all files written.
+func (w *Writer) Abort() {
+	if w.file != nil {
+		w.file.Close()
+		w.file, w.fw = nil, nil
+	}
+	for _, f := range w.files {
+		os.Remove(f)
+	}
+	w.files = nil
+}
+
+func (w *Writer) fileName() string {
+	if w.opts.MaxFileSize <= 0 {
+		return w.path
+	}
+	ext := filepath.Ext(w.path)
+	return fmt.Sprintf("%s-%05d%s", strings.TrimSuffix(w.path, ext), len(w.files), ext)
+}
+
+func (w *Writer) properties() (*parquet.WriterProperties, pqarrow.ArrowWriterProperties) {
+	mem := w.allocator()
+	props := parquet.NewWriterProperties(
+		parquet.WithAllocator(mem),
+		parquet.WithCompression(w.opts.Compression),
+		parquet.WithCreatedBy("gohive"),
+	)
+	arrowProps := []pqarrow.WriterOption{pqarrow.WithAllocator(mem), pqarrow.WithStoreSchema()}
+	if w.opts.Int96Timestamps {
+		arrowProps = append(arrowProps, pqarrow.WithDeprecatedInt96Timestamps(true))
+	}
+	return props, pqarrow.NewArrowWriterProperties(arrowProps...)
+}
+
+func (w *Writer) create() (*os.File, error) {
+	name := w.fileName()
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	w.files = append(w.files, name)
+	return f, nil
+}
+
+func (w *Writer) open() error {
+	f, err := w.create()
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.counter = &countingWriter{w: f}
+	props, arrowProps := w.properties()
+	w.fw, err = pqarrow.NewFileWriter(w.schema, w.counter, props, arrowProps)
+	if err != nil {
+		return err
+	}
+	w.fw.NewBufferedRowGroup()
+	w.rows = 0
+	return nil
+}
+
+// writeEmpty writes a file without rows. pqarrow.FileWriter cannot be
+// closed before data was written, so it uses the underlying file.Writer.
+func (w *Writer) writeEmpty() error {
+	f, err := w.create()
+	if err != nil {
+		return err
+	}
+	props, arrowProps := w.properties()
+	schema, err := pqarrow.ToParquet(w.schema, props, arrowProps)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	// file.Writer.Close closes f.
+	return file.NewParquetWriter(f, schema.Root(), file.WithWriterProps(props)).Close()
+}
+
+func (w *Writer) allocator() memory.Allocator {
+	if w.opts.Allocator == nil {
+		return memory.DefaultAllocator
+	}
+	return w.opts.Allocator
+}
+
+func (w *Writer) closeFile() error {
+	if w.fw == nil {
+		return nil
+	}
+	// FileWriter.Close closes the file too.
+	err := w.fw.Close()
+	w.file, w.fw = nil, nil
+	return err
+}
+
+// countingWriter counts the bytes written to a file.
+type countingWriter struct {
+	w *os.File
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingWriter) Close() error {
+	return c.w.Close()
+}
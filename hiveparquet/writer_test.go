@@ -0,0 +1,168 @@
+package hiveparquet_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/apache/arrow/go/v11/parquet/compress"
+	"github.com/apache/arrow/go/v11/parquet/file"
+	"github.com/apache/arrow/go/v11/parquet/pqarrow"
+	"github.com/stretchr/testify/assert"
+	_ "sqlflow.org/gohive"
+	"sqlflow.org/gohive/hiveparquet"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func openConn(t *testing.T) *sql.Conn {
+	srv := hivetest.NewServer("NOSASL")
+	t.Cleanup(srv.Close)
+	var rows [][]interface{}
+	for i := 0; i < 10; i++ {
+		var name interface{} = fmt.Sprintf("name%d", i)
+		if i%3 == 0 {
+			name = nil
+		}
+		rows = append(rows, []interface{}{i, name, fmt.Sprintf("2024-01-01 00:00:%02d", i)})
+	}
+	columns := []hivetest.Column{
+		{Name: "id", Type: hiveserver2.TTypeId_BIGINT_TYPE},
+		{Name: "name", Type: hiveserver2.TTypeId_STRING_TYPE},
+		{Name: "ts", Type: hiveserver2.TTypeId_TIMESTAMP_TYPE},
+	}
+	srv.Handle("SELECT * FROM t", &hivetest.Result{Columns: columns, Rows: rows})
+	srv.Handle("SELECT * FROM empty", &hivetest.Result{Columns: columns})
+
+	db, err := sql.Open("hive", srv.DSN()+"&batch=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readFile(t *testing.T, path string) (*file.Reader, arrow.Table) {
+	r, err := file.OpenParquetFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	fr, err := pqarrow.NewFileReader(r, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl, err := fr.ReadTable(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tbl.Release)
+	return r, tbl
+}
+
+func TestWrite(t *testing.T) {
+	a := assert.New(t)
+	conn := openConn(t)
+	path := filepath.Join(t.TempDir(), "t.parquet")
+	files, err := hiveparquet.Write(context.Background(), conn, "SELECT * FROM t", path, hiveparquet.Options{
+		RowGroupRows: 4,
+		Compression:  compress.Codecs.Snappy,
+	})
+	a.NoError(err)
+	a.Equal([]string{path}, files)
+
+	r, tbl := readFile(t, path)
+	a.Equal(int64(10), r.NumRows())
+	a.Equal(3, r.NumRowGroups())
+	cc, err := r.MetaData().RowGroup(0).ColumnChunk(0)
+	a.NoError(err)
+	a.Equal(compress.Codecs.Snappy, cc.Compression())
+
+	a.Equal("id", tbl.Schema().Field(0).Name)
+	a.Equal(arrow.INT64, tbl.Schema().Field(0).Type.ID())
+	a.Equal(arrow.TIMESTAMP, tbl.Schema().Field(2).Type.ID())
+	names := tbl.Column(1).Data().Chunk(0).(*array.String)
+	a.True(names.IsNull(0))
+	a.Equal("name1", names.Value(1))
+}
+
+func TestWriteRollover(t *testing.T) {
+	a := assert.New(t)
+	conn := openConn(t)
+	path := filepath.Join(t.TempDir(), "t.parquet")
+	files, err := hiveparquet.Write(context.Background(), conn, "SELECT * FROM t", path, hiveparquet.Options{
+		MaxFileSize: 1,
+	})
+	a.NoError(err)
+	// Every batch of 3 rows fills a file.
+	a.Equal(4, len(files))
+	a.Equal(filepath.Join(filepath.Dir(path), "t-00003.parquet"), files[3])
+	var total int64
+	for _, f := range files {
+		r, _ := readFile(t, f)
+		total += r.NumRows()
+	}
+	a.Equal(int64(10), total)
+}
+
+func TestWriteEmpty(t *testing.T) {
+	a := assert.New(t)
+	conn := openConn(t)
+	path := filepath.Join(t.TempDir(), "empty.parquet")
+	files, err := hiveparquet.Write(context.Background(), conn, "SELECT * FROM empty", path, hiveparquet.Options{})
+	a.NoError(err)
+	a.Equal([]string{path}, files)
+	r, tbl := readFile(t, path)
+	a.Equal(int64(0), r.NumRows())
+	a.Equal(3, len(tbl.Schema().Fields()))
+}
+
+// failingReader yields a record and then fails.
+type failingReader struct {
+	array.RecordReader
+	n int
+}
+
+func (r *failingReader) Next() bool {
+	r.n++
+	return r.n == 1 && r.RecordReader.Next()
+}
+
+func (r *failingReader) Err() error {
+	if r.n > 1 {
+		return errors.New("connection lost")
+	}
+	return nil
+}
+
+func TestWriteRecordsRemovesFilesOnError(t *testing.T) {
+	a := assert.New(t)
+	schema := arrow.NewSchema([]arrow.Field{{Name: "x", Type: arrow.PrimitiveTypes.Int64}}, nil)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	b.Field(0).(*array.Int64Builder).AppendValues([]int64{1, 2, 3}, nil)
+	rec := b.NewRecord()
+	defer rec.Release()
+	rr, err := array.NewRecordReader(schema, []arrow.Record{rec})
+	a.NoError(err)
+
+	dir := t.TempDir()
+	_, err = hiveparquet.WriteRecords(&failingReader{RecordReader: rr}, filepath.Join(dir, "x.parquet"),
+		hiveparquet.Options{MaxFileSize: 1})
+	a.ErrorContains(err, "connection lost")
+	entries, err := os.ReadDir(dir)
+	a.NoError(err)
+	a.Empty(entries)
+}
@@ -0,0 +1,403 @@
+// Package hivescan scans the rows of gohive queries into structs.
+//
+// A result column is stored in the struct field whose hive tag, or else
+// whose name, equals the column name, ignoring case. Hive prefixes column
+// names with the table name in some queries, e.g. "t.id" for SELECT * FROM
+// t, so a column that matches no field by its full name is matched by the
+// part after the last dot. Fields tagged `hive:"-"` are ignored, and the
+// fields of untagged embedded structs are promoted as with encoding/json.
+//
+//	type User struct {
+//		ID      int64  `hive:"id"`
+//		Name    string `hive:"user_name"`
+//		Tags    []string
+//		Created time.Time
+//		Audit
+//	}
+//
+// Values are converted from the types returned by gohive to the field
+// type. NULL becomes the zero value, or nil for pointer, slice and map
+// fields. Strings holding DECIMAL, TIMESTAMP and DATE values are parsed
+// into numeric and time.Time fields, and the JSON text of ARRAY, MAP and
+// STRUCT columns is decoded into slice, map and struct fields. Fields
+// implementing sql.Scanner scan the value themselves.
+package hivescan
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options controls how columns are matched with fields.
+type Options struct {
+	// Strict makes scanning fail if a result column matches no field or a
+	// field matches no result column. Otherwise such columns are discarded
+	// and such fields are left unchanged.
+	Strict bool
+}
+
+// Scan copies the current row of rows into the struct dest points to. Like
+// sql.Rows.Scan, it must be called after rows.Next returned true.
+func Scan(rows *sql.Rows, dest interface{}, opts Options) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("hivescan: dest must be a non-nil pointer to a struct, not %T", dest)
+	}
+	m, err := newMapping(rows, v.Elem().Type(), opts)
+	if err != nil {
+		return err
+	}
+	return m.scan(rows, v.Elem())
+}
+
+// ScanAll appends all remaining rows of rows to the slice dest points to,
+// whose elements are structs or pointers to structs. It does not close
+// rows.
+func ScanAll(rows *sql.Rows, dest interface{}, opts Options) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("hivescan: dest must be a non-nil pointer to a slice, not %T", dest)
+	}
+	slice := v.Elem()
+	elem := slice.Type().Elem()
+	isPtr := elem.Kind() == reflect.Ptr
+	if isPtr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("hivescan: dest must point to a slice of structs, not %T", dest)
+	}
+	m, err := newMapping(rows, elem, opts)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		p := reflect.New(elem)
+		if err := m.scan(rows, p.Elem()); err != nil {
+			return err
+		}
+		if isPtr {
+			slice.Set(reflect.Append(slice, p))
+		} else {
+			slice.Set(reflect.Append(slice, p.Elem()))
+		}
+	}
+	return rows.Err()
+}
+
+// field is a struct field that columns can be stored in.
+type field struct {
+	name  string
+	index []int
+}
+
+var fieldCache sync.Map // reflect.Type -> []field
+
+// fields returns the fields of struct type t in order of precedence,
+// including the fields promoted from embedded structs.
+func fields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	var fs []field
+	seen := map[string]bool{}
+	level := [][]int{nil}
+	visited := map[reflect.Type]bool{}
+	// Walk the embedded structs breadth first, so that shallower fields
+	// take precedence over promoted ones with the same name.
+	for len(level) > 0 {
+		var embedded [][]int
+		var names []field
+		for _, prefix := range level {
+			st := t
+			if len(prefix) > 0 {
+				st = t.FieldByIndex(prefix).Type
+				if st.Kind() == reflect.Ptr {
+					st = st.Elem()
+				}
+			}
+			if visited[st] {
+				continue
+			}
+			visited[st] = true
+			for i := 0; i < st.NumField(); i++ {
+				sf := st.Field(i)
+				tag := sf.Tag.Get("hive")
+				if tag == "-" {
+					continue
+				}
+				index := append(append([]int(nil), prefix...), i)
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous && tag == "" && ft.Kind() == reflect.Struct {
+					// Pointers to unexported structs cannot be allocated.
+					if sf.IsExported() || sf.Type.Kind() != reflect.Ptr {
+						embedded = append(embedded, index)
+					}
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+				name := tag
+				if name == "" {
+					name = sf.Name
+				}
+				names = append(names, field{name: strings.ToLower(name), index: index})
+			}
+		}
+		for _, f := range names {
+			if !seen[f.name] {
+				seen[f.name] = true
+				fs = append(fs, f)
+			}
+		}
+		level = embedded
+	}
+	fieldCache.Store(t, fs)
+	return fs
+}
+
+// mapping maps the columns of a result to the fields of a struct type.
+type mapping struct {
+	columns []string
+	// fields holds the field of each column, nil for discarded columns.
+	fields [][]int
+}
+
+func newMapping(rows *sql.Rows, t reflect.Type, opts Options) (*mapping, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string][]int{}
+	for _, f := range fields(t) {
+		byName[f.name] = f.index
+	}
+	m := &mapping{columns: columns, fields: make([][]int, len(columns))}
+	used := map[string]bool{}
+	for i, c := range columns {
+		name := strings.ToLower(c)
+		index, ok := byName[name]
+		if !ok {
+			name = name[strings.LastIndexByte(name, '.')+1:]
+			index, ok = byName[name]
+		}
+		if !ok {
+			if opts.Strict {
+				return nil, fmt.Errorf("hivescan: column %s matches no field of %s", c, t)
+			}
+			continue
+		}
+		if used[name] {
+			if opts.Strict {
+				return nil, fmt.Errorf("hivescan: column %s matches the field %s of %s, like a previous column", c, name, t)
+			}
+			continue
+		}
+		used[name] = true
+		m.fields[i] = index
+	}
+	if opts.Strict {
+		for _, f := range fields(t) {
+			if !used[f.name] {
+				return nil, fmt.Errorf("hivescan: field %s of %s matches no column", f.name, t)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *mapping) scan(rows *sql.Rows, v reflect.Value) error {
+	dest := make([]interface{}, len(m.columns))
+	for i, index := range m.fields {
+		if index == nil {
+			dest[i] = new(interface{})
+		} else {
+			dest[i] = &target{v: v, index: index, column: m.columns[i]}
+		}
+	}
+	return rows.Scan(dest...)
+}
+
+// target is a sql.Scanner storing a column in a struct field, allocating
+// the embedded struct pointers on the way.
+type target struct {
+	v      reflect.Value
+	index  []int
+	column string
+}
+
+func (t *target) Scan(src interface{}) error {
+	v := t.v
+	for i, x := range t.index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if src == nil {
+					return nil
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	if err := assign(v, src); err != nil {
+		return fmt.Errorf("hivescan: column %s: %v", t.column, err)
+	}
+	return nil
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// Layouts of Hive TIMESTAMP and DATE values.
+var timeLayouts = []string{"2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+// assign converts src, a value returned by gohive, and stores it in dst.
+func assign(dst reflect.Value, src interface{}) error {
+	if dst.CanAddr() && dst.Addr().Type().Implements(scannerType) {
+		return dst.Addr().Interface().(sql.Scanner).Scan(src)
+	}
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.Kind() == reflect.Ptr {
+		p := reflect.New(dst.Type().Elem())
+		if err := assign(p.Elem(), src); err != nil {
+			return err
+		}
+		dst.Set(p)
+		return nil
+	}
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dst.Type()) {
+		if b, ok := src.([]byte); ok {
+			src = append([]byte(nil), b...)
+			sv = reflect.ValueOf(src)
+		}
+		dst.Set(sv)
+		return nil
+	}
+	s, isString := src.(string)
+	if b, ok := src.([]byte); ok {
+		s, isString = string(b), true
+	}
+	switch dst.Kind() {
+	case reflect.String:
+		switch sv.Kind() {
+		case reflect.Bool, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+			dst.SetString(fmt.Sprint(src))
+			return nil
+		}
+		if isString {
+			dst.SetString(s)
+			return nil
+		}
+	case reflect.Bool:
+		if isString {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			dst.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch {
+		case sv.Kind() >= reflect.Int8 && sv.Kind() <= reflect.Int64:
+			n = sv.Int()
+		case isString:
+			var err error
+			if n, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return err
+			}
+		default:
+			return convertError(src, dst)
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %s", n, dst.Type())
+		}
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		switch {
+		case sv.Kind() >= reflect.Int8 && sv.Kind() <= reflect.Int64:
+			if sv.Int() < 0 {
+				return fmt.Errorf("value %d overflows %s", sv.Int(), dst.Type())
+			}
+			n = uint64(sv.Int())
+		case isString:
+			var err error
+			if n, err = strconv.ParseUint(s, 10, 64); err != nil {
+				return err
+			}
+		default:
+			return convertError(src, dst)
+		}
+		if dst.OverflowUint(n) {
+			return fmt.Errorf("value %d overflows %s", n, dst.Type())
+		}
+		dst.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case sv.Kind() >= reflect.Int8 && sv.Kind() <= reflect.Int64:
+			dst.SetFloat(float64(sv.Int()))
+			return nil
+		case sv.Kind() == reflect.Float64:
+			dst.SetFloat(sv.Float())
+			return nil
+		case isString:
+			f, err := strconv.ParseFloat(s, dst.Type().Bits())
+			if err != nil {
+				return err
+			}
+			dst.SetFloat(f)
+			return nil
+		}
+	case reflect.Struct:
+		if dst.Type() == timeType && isString {
+			for _, layout := range timeLayouts {
+				if t, err := time.Parse(layout, s); err == nil {
+					dst.Set(reflect.ValueOf(t))
+					return nil
+				}
+			}
+			return fmt.Errorf("cannot parse %q as a TIMESTAMP or DATE", s)
+		}
+		if isString {
+			return json.Unmarshal([]byte(s), dst.Addr().Interface())
+		}
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 && isString {
+			dst.SetBytes([]byte(s))
+			return nil
+		}
+		if isString {
+			return json.Unmarshal([]byte(s), dst.Addr().Interface())
+		}
+	case reflect.Map, reflect.Array:
+		if isString {
+			return json.Unmarshal([]byte(s), dst.Addr().Interface())
+		}
+	}
+	return convertError(src, dst)
+}
+
+func convertError(src interface{}, dst reflect.Value) error {
+	return fmt.Errorf("cannot convert %T to %s", src, dst.Type())
+}
@@ -0,0 +1,124 @@
+package hivescan_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	_ "sqlflow.org/gohive"
+	"sqlflow.org/gohive/hivescan"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+type Audit struct {
+	Created time.Time `hive:"created_at"`
+	Note    *string
+}
+
+type User struct {
+	ID     int64  `hive:"id"`
+	Name   string `hive:"user_name"`
+	Age    int
+	Score  float64
+	Tags   []string
+	Attrs  map[string]int
+	Secret string `hive:"-"`
+	*Audit
+}
+
+var users = &hivetest.Result{
+	Columns: []hivetest.Column{
+		{Name: "u.id", Type: hiveserver2.TTypeId_BIGINT_TYPE},
+		{Name: "u.user_name", Type: hiveserver2.TTypeId_STRING_TYPE},
+		{Name: "u.age", Type: hiveserver2.TTypeId_TINYINT_TYPE},
+		{Name: "u.score", Type: hiveserver2.TTypeId_DECIMAL_TYPE},
+		{Name: "u.tags", Type: hiveserver2.TTypeId_ARRAY_TYPE},
+		{Name: "u.attrs", Type: hiveserver2.TTypeId_MAP_TYPE},
+		{Name: "u.created_at", Type: hiveserver2.TTypeId_TIMESTAMP_TYPE},
+		{Name: "u.note", Type: hiveserver2.TTypeId_STRING_TYPE},
+	},
+	Rows: [][]interface{}{
+		{1, "alice", 31, "12.50", `["a","b"]`, `{"x":1}`, "2023-04-05 06:07:08.9", "vip"},
+		{2, "bob", nil, nil, nil, nil, nil, nil},
+	},
+}
+
+func query(t *testing.T, res *hivetest.Result) *sql.Rows {
+	srv := hivetest.NewServer("NOSASL")
+	t.Cleanup(srv.Close)
+	srv.Handle("SELECT * FROM users u", res)
+	db, err := sql.Open("hive", srv.DSN()+"&pollInterval=1ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	rows, err := db.QueryContext(context.Background(), "SELECT * FROM users u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rows.Close() })
+	return rows
+}
+
+func TestScanAll(t *testing.T) {
+	a := assert.New(t)
+	var got []User
+	a.NoError(hivescan.ScanAll(query(t, users), &got, hivescan.Options{Strict: true}))
+	note := "vip"
+	a.Equal([]User{{
+		ID:    1,
+		Name:  "alice",
+		Age:   31,
+		Score: 12.5,
+		Tags:  []string{"a", "b"},
+		Attrs: map[string]int{"x": 1},
+		Audit: &Audit{Created: time.Date(2023, 4, 5, 6, 7, 8, 9e8, time.UTC), Note: &note},
+	}, {
+		ID:   2,
+		Name: "bob",
+	}}, got)
+}
+
+func TestScan(t *testing.T) {
+	a := assert.New(t)
+	rows := query(t, users)
+	a.True(rows.Next())
+	var u struct {
+		ID   *int64 `hive:"id"`
+		Name []byte `hive:"user_name"`
+	}
+	a.NoError(hivescan.Scan(rows, &u, hivescan.Options{}))
+	a.Equal(int64(1), *u.ID)
+	a.Equal("alice", string(u.Name))
+
+	a.Error(hivescan.Scan(rows, u, hivescan.Options{}))
+}
+
+func TestScanStrict(t *testing.T) {
+	a := assert.New(t)
+	var missingField []struct {
+		ID int64 `hive:"id"`
+	}
+	a.Error(hivescan.ScanAll(query(t, users), &missingField, hivescan.Options{Strict: true}))
+
+	var missingColumn []struct {
+		User
+		Email string
+	}
+	a.Error(hivescan.ScanAll(query(t, users), &missingColumn, hivescan.Options{Strict: true}))
+	a.NoError(hivescan.ScanAll(query(t, users), &missingColumn, hivescan.Options{}))
+	a.Len(missingColumn, 2)
+	a.Equal("alice", missingColumn[0].Name)
+}
+
+func TestScanConversionError(t *testing.T) {
+	var got []struct {
+		Name int `hive:"user_name"`
+	}
+	err := hivescan.ScanAll(query(t, users), &got, hivescan.Options{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "u.user_name")
+}
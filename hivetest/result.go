@@ -0,0 +1,251 @@
+package hivetest
+
+import (
+	"fmt"
+	"reflect"
+
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+// Column describes a column of a scripted result.
+type Column struct {
+	Name string
+	Type hiveserver2.TTypeId
+	// TypeDesc overrides the type descriptor derived from Type, e.g. to
+	// add DECIMAL qualifiers or nested type entries.
+	TypeDesc *hiveserver2.TTypeDesc
+}
+
+// Result scripts how the server answers a statement.
+type Result struct {
+	Columns []Column
+	// Rows holds the values of each row, nil values are NULL. Values are
+	// converted to the Thrift representation of the column type, e.g. any
+	// Go integer fits a BIGINT_TYPE column.
+	Rows [][]interface{}
+
+	// ExecuteError makes ExecuteStatement itself fail, like a statement
+	// that does not compile.
+	ExecuteError string
+	// Running is the number of GetOperationStatus calls answered with
+	// RUNNING_STATE before the operation reaches its final state.
+	Running int
+	// Progress is the progressed percentage reported by successive
+	// GetOperationStatus calls while running, if the client asks for it.
+	Progress []float64
+	// Error makes the operation end in ERROR_STATE with this message.
+	Error string
+	// State overrides the final operation state, which is FINISHED_STATE,
+	// or ERROR_STATE if Error is set.
+	State *hiveserver2.TOperationState
+	// Logs is the operation log returned by FetchResults with FetchType 1.
+	Logs []string
+	// QueryID is returned by GetQueryId, a generated ID by default.
+	QueryID string
+	// RowsAffected is reported as the modified row count of the operation.
+	RowsAffected *int64
+}
+
+func (r *Result) finalState() hiveserver2.TOperationState {
+	switch {
+	case r.State != nil:
+		return *r.State
+	case r.Error != "":
+		return hiveserver2.TOperationState_ERROR_STATE
+	}
+	return hiveserver2.TOperationState_FINISHED_STATE
+}
+
+func (r *Result) schema() *hiveserver2.TTableSchema {
+	schema := hiveserver2.NewTTableSchema()
+	for i, c := range r.Columns {
+		desc := c.TypeDesc
+		if desc == nil {
+			desc = &hiveserver2.TTypeDesc{Types: []*hiveserver2.TTypeEntry{{
+				PrimitiveEntry: &hiveserver2.TPrimitiveTypeEntry{Type: c.Type},
+			}}}
+		}
+		schema.Columns = append(schema.Columns, &hiveserver2.TColumnDesc{
+			ColumnName: c.Name,
+			TypeDesc:   desc,
+			Position:   int32(i + 1),
+		})
+	}
+	return schema
+}
+
+// columns encodes rows in the column-oriented TRowSet format.
+func (r *Result) columns(rows [][]interface{}) []*hiveserver2.TColumn {
+	cols := make([]*hiveserver2.TColumn, len(r.Columns))
+	for i, c := range r.Columns {
+		values := make([]interface{}, len(rows))
+		for j, row := range rows {
+			values[j] = row[i]
+		}
+		cols[i] = encodeColumn(c.Type, values)
+	}
+	return cols
+}
+
+// rows encodes rows in the row-oriented TRowSet format of older servers.
+func (r *Result) rows(rows [][]interface{}) []*hiveserver2.TRow {
+	ret := make([]*hiveserver2.TRow, len(rows))
+	for j, row := range rows {
+		vals := make([]*hiveserver2.TColumnValue, len(r.Columns))
+		for i, c := range r.Columns {
+			vals[i] = encodeValue(c.Type, row[i])
+		}
+		ret[j] = &hiveserver2.TRow{ColVals: vals}
+	}
+	return ret
+}
+
+func encodeColumn(t hiveserver2.TTypeId, values []interface{}) *hiveserver2.TColumn {
+	nulls := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v == nil {
+			nulls[i/8] |= 1 << (uint(i) % 8)
+		}
+	}
+	col := hiveserver2.NewTColumn()
+	switch t {
+	case hiveserver2.TTypeId_BOOLEAN_TYPE:
+		vs := make([]bool, len(values))
+		for i, v := range values {
+			vs[i] = v != nil && reflect.ValueOf(v).Bool()
+		}
+		col.BoolVal = &hiveserver2.TBoolColumn{Values: vs, Nulls: nulls}
+	case hiveserver2.TTypeId_TINYINT_TYPE:
+		vs := make([]int8, len(values))
+		for i, v := range values {
+			vs[i] = int8(toInt64(v))
+		}
+		col.ByteVal = &hiveserver2.TByteColumn{Values: vs, Nulls: nulls}
+	case hiveserver2.TTypeId_SMALLINT_TYPE:
+		vs := make([]int16, len(values))
+		for i, v := range values {
+			vs[i] = int16(toInt64(v))
+		}
+		col.I16Val = &hiveserver2.TI16Column{Values: vs, Nulls: nulls}
+	case hiveserver2.TTypeId_INT_TYPE:
+		vs := make([]int32, len(values))
+		for i, v := range values {
+			vs[i] = int32(toInt64(v))
+		}
+		col.I32Val = &hiveserver2.TI32Column{Values: vs, Nulls: nulls}
+	case hiveserver2.TTypeId_BIGINT_TYPE:
+		vs := make([]int64, len(values))
+		for i, v := range values {
+			vs[i] = toInt64(v)
+		}
+		col.I64Val = &hiveserver2.TI64Column{Values: vs, Nulls: nulls}
+	case hiveserver2.TTypeId_FLOAT_TYPE, hiveserver2.TTypeId_DOUBLE_TYPE:
+		vs := make([]float64, len(values))
+		for i, v := range values {
+			vs[i] = toFloat64(v)
+		}
+		col.DoubleVal = &hiveserver2.TDoubleColumn{Values: vs, Nulls: nulls}
+	case hiveserver2.TTypeId_BINARY_TYPE:
+		vs := make([][]byte, len(values))
+		for i, v := range values {
+			vs[i] = []byte(toString(v))
+		}
+		col.BinaryVal = &hiveserver2.TBinaryColumn{Values: vs, Nulls: nulls}
+	default:
+		vs := make([]string, len(values))
+		for i, v := range values {
+			vs[i] = toString(v)
+		}
+		col.StringVal = &hiveserver2.TStringColumn{Values: vs, Nulls: nulls}
+	}
+	return col
+}
+
+func encodeValue(t hiveserver2.TTypeId, v interface{}) *hiveserver2.TColumnValue {
+	val := hiveserver2.NewTColumnValue()
+	switch t {
+	case hiveserver2.TTypeId_BOOLEAN_TYPE:
+		val.BoolVal = hiveserver2.NewTBoolValue()
+		if v != nil {
+			b := reflect.ValueOf(v).Bool()
+			val.BoolVal.Value = &b
+		}
+	case hiveserver2.TTypeId_TINYINT_TYPE:
+		val.ByteVal = hiveserver2.NewTByteValue()
+		if v != nil {
+			i := int8(toInt64(v))
+			val.ByteVal.Value = &i
+		}
+	case hiveserver2.TTypeId_SMALLINT_TYPE:
+		val.I16Val = hiveserver2.NewTI16Value()
+		if v != nil {
+			i := int16(toInt64(v))
+			val.I16Val.Value = &i
+		}
+	case hiveserver2.TTypeId_INT_TYPE:
+		val.I32Val = hiveserver2.NewTI32Value()
+		if v != nil {
+			i := int32(toInt64(v))
+			val.I32Val.Value = &i
+		}
+	case hiveserver2.TTypeId_BIGINT_TYPE:
+		val.I64Val = hiveserver2.NewTI64Value()
+		if v != nil {
+			i := toInt64(v)
+			val.I64Val.Value = &i
+		}
+	case hiveserver2.TTypeId_FLOAT_TYPE, hiveserver2.TTypeId_DOUBLE_TYPE:
+		val.DoubleVal = hiveserver2.NewTDoubleValue()
+		if v != nil {
+			f := toFloat64(v)
+			val.DoubleVal.Value = &f
+		}
+	default:
+		val.StringVal = hiveserver2.NewTStringValue()
+		if v != nil {
+			s := toString(v)
+			val.StringVal.Value = &s
+		}
+	}
+	return val
+}
+
+func toInt64(v interface{}) int64 {
+	if v == nil {
+		return 0
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int64(rv.Float())
+	}
+	panic(fmt.Sprintf("hivetest: %T is not an integer", v))
+}
+
+func toFloat64(v interface{}) float64 {
+	if v == nil {
+		return 0
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	}
+	return float64(toInt64(v))
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	}
+	return fmt.Sprint(v)
+}
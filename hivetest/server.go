@@ -0,0 +1,256 @@
+// Package hivetest provides an in-process HiveServer2 for hermetic tests of
+// code that uses gohive.
+//
+// A Server implements the generated TCLIService interface in memory and
+// answers statements with scripted Results:
+//
+//	srv := hivetest.NewServer("NOSASL")
+//	defer srv.Close()
+//	srv.Handle("SELECT 1", &hivetest.Result{
+//		Columns: []hivetest.Column{{Name: "_c0", Type: hiveserver2.TTypeId_INT_TYPE}},
+//		Rows:    [][]interface{}{{1}},
+//	})
+//	db, err := sql.Open("hive", srv.DSN())
+package hivetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+// SASL negotiation status bytes.
+const (
+	saslStart    = 1
+	saslOK       = 2
+	saslBad      = 3
+	saslError    = 4
+	saslComplete = 5
+)
+
+// Server is an in-process HiveServer2 listening on a local port.
+type Server struct {
+	// Auth is "NOSASL" or "PLAIN", as in the auth option of a gohive DSN.
+	Auth string
+	// Users holds the accepted PLAIN credentials. A nil map accepts any.
+	Users map[string]string
+	// RowBased makes FetchResults return row-oriented TRowSets, like Hive
+	// releases before HIVE_CLI_SERVICE_PROTOCOL_V6.
+	RowBased bool
+
+	listener net.Listener
+	service  *service
+	wg       sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// NewServer starts a Server using the auth mechanism auth. It panics if it
+// cannot listen, like net/http/httptest.
+func NewServer(auth string) *Server {
+	s := NewUnstartedServer(auth)
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a Server that is not started yet, so that its
+// fields can be changed before calling Start.
+func NewUnstartedServer(auth string) *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("hivetest: failed to listen on a port: %v", err))
+	}
+	return &Server{
+		Auth:     auth,
+		listener: l,
+		service:  newService(),
+		conns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// Start starts serving connections.
+func (s *Server) Start() {
+	if s.Auth != "NOSASL" && s.Auth != "PLAIN" {
+		panic(fmt.Sprintf("hivetest: unsupported auth mechanism %s", s.Auth))
+	}
+	s.service.rowBased = s.RowBased
+	s.wg.Add(1)
+	go s.serve()
+}
+
+// Addr returns the host:port the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// DSN returns a gohive DSN connecting to the server. With PLAIN
+// authentication and Users set, credentials have to be added to it.
+func (s *Server) DSN() string {
+	return fmt.Sprintf("%s?auth=%s", s.Addr(), s.Auth)
+}
+
+// Close stops the server and closes all client connections.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Handle scripts the result of stmt. Statements are matched exactly, after
+// gohive removed the trailing semicolon and substituted variables.
+func (s *Server) Handle(stmt string, r *Result) {
+	s.service.handle(stmt, r)
+}
+
+// HandleFunc sets a function answering statements that have no result
+// registered with Handle. A nil Result makes ExecuteStatement fail.
+func (s *Server) HandleFunc(f func(stmt string) *Result) {
+	s.service.handleFunc(f)
+}
+
+// SetSessionConfig sets the configuration that OpenSession returns to
+// subsequent sessions, e.g. the result set compressor hiveserver2 picked.
+func (s *Server) SetSessionConfig(conf map[string]string) {
+	s.service.setSessionConfig(conf)
+}
+
+// Statements returns the statements executed so far, in order.
+func (s *Server) Statements() []Statement {
+	return s.service.statements()
+}
+
+// Calls returns the number of calls of a TCLIService method received so
+// far, e.g. Calls("CancelOperation").
+func (s *Server) Calls(method string) int {
+	return s.service.callCount(method)
+}
+
+// Sessions returns the number of open sessions.
+func (s *Server) Sessions() int {
+	return s.service.sessionCount()
+}
+
+// ExpireSessions forgets all sessions, so that subsequent requests on them
+// fail with "Invalid SessionHandle" as after a hiveserver2 restart.
+func (s *Server) ExpireSessions() {
+	s.service.expireSessions()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	socket := thrift.NewTSocketFromConnConf(conn, nil)
+	var transport thrift.TTransport
+	if s.Auth == "PLAIN" {
+		if err := s.negotiate(conn); err != nil {
+			return
+		}
+		transport = thrift.NewTFramedTransportConf(socket, nil)
+	} else {
+		transport = thrift.NewTBufferedTransport(socket, 4096)
+	}
+	protocol := &countingProtocol{thrift.NewTBinaryProtocolConf(transport, nil), s.service}
+	processor := hiveserver2.NewTCLIServiceProcessor(s.service)
+	for {
+		ok, err := processor.Process(context.Background(), protocol, protocol)
+		if err != nil || !ok {
+			return
+		}
+	}
+}
+
+// countingProtocol counts the calls of each method.
+type countingProtocol struct {
+	thrift.TProtocol
+	service *service
+}
+
+func (p *countingProtocol) ReadMessageBegin(ctx context.Context) (string, thrift.TMessageType, int32, error) {
+	name, typeID, seqID, err := p.TProtocol.ReadMessageBegin(ctx)
+	if err == nil {
+		p.service.called(name)
+	}
+	return name, typeID, seqID, err
+}
+
+// negotiate runs the server side of a SASL PLAIN negotiation.
+func (s *Server) negotiate(conn net.Conn) error {
+	status, mechanism, err := readSaslMessage(conn)
+	if err != nil {
+		return err
+	}
+	if status != saslStart || string(mechanism) != "PLAIN" {
+		writeSaslMessage(conn, saslBad, []byte("unsupported mechanism"))
+		return fmt.Errorf("hivetest: unexpected SASL start %d %s", status, mechanism)
+	}
+	status, payload, err := readSaslMessage(conn)
+	if err != nil {
+		return err
+	}
+	// The PLAIN payload is authzid NUL authcid NUL password.
+	parts := bytes.SplitN(payload, []byte{0}, 3)
+	if status != saslOK || len(parts) != 3 {
+		writeSaslMessage(conn, saslError, []byte("malformed PLAIN payload"))
+		return fmt.Errorf("hivetest: malformed SASL PLAIN payload")
+	}
+	if s.Users != nil {
+		if passwd, found := s.Users[string(parts[1])]; !found || passwd != string(parts[2]) {
+			writeSaslMessage(conn, saslBad, []byte("authentication failed"))
+			return fmt.Errorf("hivetest: authentication failed for %s", parts[1])
+		}
+	}
+	return writeSaslMessage(conn, saslComplete, nil)
+}
+
+func readSaslMessage(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+func writeSaslMessage(w io.Writer, status byte, payload []byte) error {
+	msg := make([]byte, 5+len(payload))
+	msg[0] = status
+	binary.BigEndian.PutUint32(msg[1:], uint32(len(payload)))
+	copy(msg[5:], payload)
+	_, err := w.Write(msg)
+	return err
+}
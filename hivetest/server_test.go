@@ -0,0 +1,309 @@
+package hivetest_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sqlflow.org/gohive"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+var people = &hivetest.Result{
+	Columns: []hivetest.Column{
+		{Name: "name", Type: hiveserver2.TTypeId_STRING_TYPE},
+		{Name: "age", Type: hiveserver2.TTypeId_INT_TYPE},
+	},
+	Rows: [][]interface{}{{"alice", 31}, {"bob", nil}, {nil, 7}},
+}
+
+func openDB(t *testing.T, srv *hivetest.Server, dsn string) *sql.DB {
+	db, err := sql.Open("hive", dsn+"&pollInterval=1ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func queryPeople(t *testing.T, db *sql.DB) {
+	a := assert.New(t)
+	rows, err := db.Query("SELECT name, age FROM people")
+	a.NoError(err)
+	defer rows.Close()
+	var got [][]interface{}
+	for rows.Next() {
+		var name sql.NullString
+		var age sql.NullInt32
+		a.NoError(rows.Scan(&name, &age))
+		got = append(got, []interface{}{name, age})
+	}
+	a.NoError(rows.Err())
+	a.Equal([][]interface{}{
+		{sql.NullString{String: "alice", Valid: true}, sql.NullInt32{Int32: 31, Valid: true}},
+		{sql.NullString{String: "bob", Valid: true}, sql.NullInt32{}},
+		{sql.NullString{}, sql.NullInt32{Int32: 7, Valid: true}},
+	}, got)
+}
+
+func TestQuery(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		auth     string
+		rowBased bool
+	}{
+		{"NOSASL", "NOSASL", false},
+		{"PLAIN", "PLAIN", false},
+		{"RowBased", "NOSASL", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := hivetest.NewUnstartedServer(tc.auth)
+			srv.RowBased = tc.rowBased
+			srv.Start()
+			defer srv.Close()
+			srv.Handle("SELECT name, age FROM people", people)
+
+			dsn := srv.DSN()
+			if tc.auth == "PLAIN" {
+				dsn = "user:secret@" + dsn
+			}
+			queryPeople(t, openDB(t, srv, dsn))
+		})
+	}
+}
+
+func TestPLAINCredentials(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewUnstartedServer("PLAIN")
+	srv.Users = map[string]string{"user": "secret"}
+	srv.Start()
+	defer srv.Close()
+	srv.Handle("SELECT name, age FROM people", people)
+
+	a.Error(openDB(t, srv, "user:wrong@"+srv.DSN()).Ping())
+	queryPeople(t, openDB(t, srv, "user:secret@"+srv.DSN()))
+}
+
+func TestAsyncStates(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("SELECT name, age FROM people", &hivetest.Result{
+		Columns:  people.Columns,
+		Rows:     people.Rows,
+		Running:  3,
+		Progress: []float64{0.1, 0.5, 0.9},
+	})
+	srv.Handle("INSERT INTO people VALUES ('carol', 40)", &hivetest.Result{
+		Running:      2,
+		RowsAffected: func() *int64 { n := int64(1); return &n }(),
+	})
+	db := openDB(t, srv, srv.DSN())
+
+	queryPeople(t, db)
+	res, err := db.Exec("INSERT INTO people VALUES ('carol', 40)")
+	a.NoError(err)
+	n, err := res.RowsAffected()
+	a.NoError(err)
+	a.Equal(int64(1), n)
+}
+
+func TestErrors(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("SELECT * FROM missing", &hivetest.Result{
+		ExecuteError: "Error while compiling statement: Table not found missing",
+	})
+	srv.Handle("SELECT fail()", &hivetest.Result{
+		Running: 1,
+		Error:   "Error while processing statement: FAILED: Execution Error",
+		QueryID: "hive_20240101_1",
+	})
+	timedOut := hiveserver2.TOperationState_TIMEDOUT_STATE
+	srv.Handle("SELECT slow()", &hivetest.Result{Running: 1, State: &timedOut})
+	db := openDB(t, srv, srv.DSN())
+
+	_, err := db.Exec("SELECT * FROM missing")
+	a.ErrorContains(err, "Table not found missing")
+
+	_, err = db.Exec("SELECT fail()")
+	var opErr *gohive.OperationError
+	a.True(errors.As(err, &opErr))
+	a.Equal("hive_20240101_1", opErr.QueryID)
+	a.ErrorContains(err, "Execution Error")
+
+	_, err = db.Exec("SELECT slow()")
+	a.True(errors.Is(err, gohive.ErrQueryTimeout))
+
+	_, err = db.Exec("SELECT unscripted")
+	a.ErrorContains(err, "no result for statement")
+}
+
+func TestQueryIDFunc(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("SELECT 1", &hivetest.Result{
+		Columns: []hivetest.Column{{Name: "_c0", Type: hiveserver2.TTypeId_INT_TYPE}},
+		Rows:    [][]interface{}{{int32(1)}},
+		QueryID: "hive_1",
+	})
+	srv.Handle("INSERT INTO t VALUES (1)", &hivetest.Result{Running: 1, QueryID: "hive_2"})
+	db := openDB(t, srv, srv.DSN())
+
+	var ids []string
+	ctx := gohive.WithQueryIDFunc(context.Background(), func(queryID, operationID string) {
+		a.NotEmpty(operationID)
+		ids = append(ids, queryID)
+	})
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	a.NoError(err)
+	a.NoError(rows.Close())
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	a.NoError(err)
+	a.Equal([]string{"hive_1", "hive_2"}, ids)
+}
+
+func TestStatements(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.HandleFunc(func(stmt string) *hivetest.Result { return &hivetest.Result{} })
+	db := openDB(t, srv, srv.DSN()+"&hivevar.day=2024-01-01&queryTimeout=30s")
+
+	ctx := gohive.WithConfOverlay(context.Background(), gohive.ConfOverlay{"hive.exec.parallel": "true"})
+	_, err := db.ExecContext(ctx, "INSERT OVERWRITE TABLE t SELECT 1;")
+	a.NoError(err)
+
+	stmts := srv.Statements()
+	a.Equal(1, len(stmts))
+	a.Equal("INSERT OVERWRITE TABLE t SELECT 1", stmts[0].Statement)
+	a.Equal(map[string]string{"hive.exec.parallel": "true"}, stmts[0].ConfOverlay)
+	a.Equal(int64(30), stmts[0].QueryTimeout)
+	a.True(stmts[0].RunAsync)
+	a.Equal("2024-01-01", stmts[0].Session["set:hivevar:day"])
+}
+
+func TestHandleFuncCallsServer(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	// The fallback may use the server, e.g. to register the result for
+	// the next execution of the statement.
+	srv.HandleFunc(func(stmt string) *hivetest.Result {
+		r := &hivetest.Result{}
+		if len(srv.Statements()) == 1 && srv.Calls("ExecuteStatement") == 1 {
+			srv.Handle(stmt, &hivetest.Result{ExecuteError: "already ran"})
+		}
+		return r
+	})
+	db := openDB(t, srv, srv.DSN())
+
+	_, err := db.Exec("INSERT INTO t VALUES (1)")
+	a.NoError(err)
+	_, err = db.Exec("INSERT INTO t VALUES (1)")
+	a.ErrorContains(err, "already ran")
+}
+
+func TestLogs(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("SELECT 1", &hivetest.Result{
+		Columns: []hivetest.Column{{Name: "_c0", Type: hiveserver2.TTypeId_INT_TYPE}},
+		Rows:    [][]interface{}{{1}},
+		Running: 1,
+		Logs:    []string{"Compiling command", "Completed executing command"},
+	})
+	db := openDB(t, srv, srv.DSN())
+
+	conn, err := db.Conn(context.Background())
+	a.NoError(err)
+	defer conn.Close()
+	var logs bytes.Buffer
+	a.NoError(conn.Raw(func(c interface{}) error {
+		c.(interface{ SetLogWriter(w io.Writer) }).SetLogWriter(&logs)
+		return nil
+	}))
+	var one int32
+	a.NoError(conn.QueryRowContext(context.Background(), "SELECT 1").Scan(&one))
+	a.Equal(int32(1), one)
+	a.Equal("Compiling command\nCompleted executing command\n", logs.String())
+
+	// A context writer takes precedence, and failing to write logs does not
+	// fail the query.
+	var ctxLogs bytes.Buffer
+	ctx := gohive.WithLogWriter(context.Background(), &ctxLogs)
+	a.NoError(conn.QueryRowContext(ctx, "SELECT 1").Scan(&one))
+	a.Equal("Compiling command\nCompleted executing command\n", ctxLogs.String())
+	a.Equal("Compiling command\nCompleted executing command\n", logs.String())
+	ctx = gohive.WithLogWriter(context.Background(), failingWriter{})
+	a.NoError(conn.QueryRowContext(ctx, "SELECT 1").Scan(&one))
+}
+
+func TestOperationLogs(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("INSERT INTO t SELECT 1", &hivetest.Result{
+		Running: 2,
+		Logs:    []string{"Compiling command", "Completed executing command"},
+	})
+	db := openDB(t, srv, srv.DSN())
+	conn, err := db.Conn(context.Background())
+	a.NoError(err)
+	defer conn.Close()
+
+	a.NoError(conn.Raw(func(c interface{}) error {
+		op, err := c.(interface {
+			StartOperation(context.Context, string) (*gohive.Operation, error)
+		}).StartOperation(context.Background(), "INSERT INTO t SELECT 1")
+		a.NoError(err)
+		defer op.Close()
+		a.NotEmpty(op.OperationID())
+
+		done, err := op.Poll()
+		a.NoError(err)
+		a.False(done)
+		logs, err := op.FetchLogs()
+		a.NoError(err)
+		a.Equal([]string{"Compiling command", "Completed executing command"}, logs)
+
+		a.NoError(op.Wait())
+		done, err = op.Poll()
+		a.NoError(err)
+		a.True(done)
+		logs, err = op.FetchLogs()
+		a.NoError(err)
+		a.Empty(logs)
+		return nil
+	}))
+	a.Equal(1, srv.Calls("CloseOperation"))
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestExpireSessions(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("SELECT name, age FROM people", people)
+	db := openDB(t, srv, srv.DSN())
+	db.SetMaxOpenConns(1)
+
+	queryPeople(t, db)
+	srv.ExpireSessions()
+	// database/sql retries on a new session after the old one is rejected.
+	queryPeople(t, db)
+	a.Equal(1, srv.Sessions())
+}
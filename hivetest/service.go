@@ -0,0 +1,442 @@
+package hivetest
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"sync"
+
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+// Statement records an ExecuteStatement request.
+type Statement struct {
+	Statement    string
+	ConfOverlay  map[string]string
+	QueryTimeout int64
+	RunAsync     bool
+	// Session is the configuration the session was opened with.
+	Session map[string]string
+}
+
+type session struct {
+	config map[string]string
+}
+
+type operation struct {
+	result  *Result
+	state   hiveserver2.TOperationState
+	polls   int
+	fetched int
+	logs    int
+}
+
+// service implements hiveserver2.TCLIService in memory.
+type service struct {
+	mu         sync.Mutex
+	rowBased   bool
+	results    map[string]*Result
+	fallback   func(stmt string) *Result
+	sessionCfg map[string]string
+	executed   []Statement
+	sessions   map[string]*session
+	operations map[string]*operation
+	calls      map[string]int
+}
+
+func newService() *service {
+	return &service{
+		results:    make(map[string]*Result),
+		sessions:   make(map[string]*session),
+		operations: make(map[string]*operation),
+		calls:      make(map[string]int),
+	}
+}
+
+func (s *service) handle(stmt string, r *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[strings.TrimSpace(stmt)] = r
+}
+
+func (s *service) handleFunc(f func(stmt string) *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = f
+}
+
+func (s *service) setSessionConfig(conf map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionCfg = conf
+}
+
+func (s *service) statements() []Statement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Statement(nil), s.executed...)
+}
+
+func (s *service) called(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[method]++
+}
+
+func (s *service) callCount(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *service) sessionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+func (s *service) expireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = make(map[string]*session)
+	s.operations = make(map[string]*operation)
+}
+
+func newHandleIdentifier() *hiveserver2.THandleIdentifier {
+	id := &hiveserver2.THandleIdentifier{GUID: make([]byte, 16), Secret: make([]byte, 16)}
+	rand.Read(id.GUID)
+	rand.Read(id.Secret)
+	return id
+}
+
+func successStatus() *hiveserver2.TStatus {
+	return &hiveserver2.TStatus{StatusCode: hiveserver2.TStatusCode_SUCCESS_STATUS}
+}
+
+func errorStatus(format string, args ...interface{}) *hiveserver2.TStatus {
+	msg := fmt.Sprintf(format, args...)
+	return &hiveserver2.TStatus{StatusCode: hiveserver2.TStatusCode_ERROR_STATUS, ErrorMessage: &msg}
+}
+
+// lookupSession must be called with s.mu held.
+func (s *service) lookupSession(h *hiveserver2.TSessionHandle) (*session, *hiveserver2.TStatus) {
+	if h == nil || h.SessionId == nil {
+		return nil, errorStatus("Invalid SessionHandle: nil")
+	}
+	sess, found := s.sessions[string(h.SessionId.GUID)]
+	if !found {
+		return nil, errorStatus("Invalid SessionHandle: %x", h.SessionId.GUID)
+	}
+	return sess, nil
+}
+
+// lookupOperation must be called with s.mu held.
+func (s *service) lookupOperation(h *hiveserver2.TOperationHandle) (*operation, *hiveserver2.TStatus) {
+	if h == nil || h.OperationId == nil {
+		return nil, errorStatus("Invalid OperationHandle: nil")
+	}
+	op, found := s.operations[string(h.OperationId.GUID)]
+	if !found {
+		return nil, errorStatus("Invalid OperationHandle: %x", h.OperationId.GUID)
+	}
+	return op, nil
+}
+
+func (s *service) OpenSession(ctx context.Context, req *hiveserver2.TOpenSessionReq) (*hiveserver2.TOpenSessionResp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := newHandleIdentifier()
+	config := make(map[string]string)
+	for k, v := range req.Configuration {
+		config[k] = v
+	}
+	s.sessions[string(id.GUID)] = &session{config: config}
+	resp := hiveserver2.NewTOpenSessionResp()
+	resp.Status = successStatus()
+	resp.ServerProtocolVersion = req.ClientProtocol
+	resp.SessionHandle = &hiveserver2.TSessionHandle{SessionId: id}
+	resp.Configuration = map[string]string{}
+	for k, v := range s.sessionCfg {
+		resp.Configuration[k] = v
+	}
+	return resp, nil
+}
+
+func (s *service) CloseSession(ctx context.Context, req *hiveserver2.TCloseSessionReq) (*hiveserver2.TCloseSessionResp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, status := s.lookupSession(req.SessionHandle); status != nil {
+		return &hiveserver2.TCloseSessionResp{Status: status}, nil
+	}
+	delete(s.sessions, string(req.SessionHandle.SessionId.GUID))
+	return &hiveserver2.TCloseSessionResp{Status: successStatus()}, nil
+}
+
+func (s *service) GetInfo(ctx context.Context, req *hiveserver2.TGetInfoReq) (*hiveserver2.TGetInfoResp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, status := s.lookupSession(req.SessionHandle); status != nil {
+		return &hiveserver2.TGetInfoResp{Status: status, InfoValue: hiveserver2.NewTGetInfoValue()}, nil
+	}
+	var v string
+	switch req.InfoType {
+	case hiveserver2.TGetInfoType_CLI_SERVER_NAME, hiveserver2.TGetInfoType_CLI_DBMS_NAME:
+		v = "Hive"
+	case hiveserver2.TGetInfoType_CLI_DBMS_VER:
+		v = "hivetest"
+	}
+	return &hiveserver2.TGetInfoResp{Status: successStatus(), InfoValue: &hiveserver2.TGetInfoValue{StringValue: &v}}, nil
+}
+
+func (s *service) ExecuteStatement(ctx context.Context, req *hiveserver2.TExecuteStatementReq) (*hiveserver2.TExecuteStatementResp, error) {
+	s.mu.Lock()
+	sess, status := s.lookupSession(req.SessionHandle)
+	if status != nil {
+		s.mu.Unlock()
+		return &hiveserver2.TExecuteStatementResp{Status: status}, nil
+	}
+	stmt := strings.TrimSpace(req.Statement)
+	s.executed = append(s.executed, Statement{
+		Statement:    stmt,
+		ConfOverlay:  req.ConfOverlay,
+		QueryTimeout: req.QueryTimeout,
+		RunAsync:     req.RunAsync,
+		Session:      sess.config,
+	})
+	r, found := s.results[stmt]
+	fallback := s.fallback
+	s.mu.Unlock()
+	// The fallback runs unlocked, so that it may call the Server.
+	if !found && fallback != nil {
+		r = fallback(stmt)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r == nil {
+		return &hiveserver2.TExecuteStatementResp{
+			Status: errorStatus("hivetest: no result for statement %q", stmt),
+		}, nil
+	}
+	if r.ExecuteError != "" {
+		return &hiveserver2.TExecuteStatementResp{Status: errorStatus("%s", r.ExecuteError)}, nil
+	}
+
+	op := &operation{result: r, state: hiveserver2.TOperationState_RUNNING_STATE}
+	if !req.RunAsync {
+		// Synchronous statements complete before ExecuteStatement returns.
+		op.polls = r.Running
+		op.state = r.finalState()
+	}
+	id := newHandleIdentifier()
+	s.operations[string(id.GUID)] = op
+	handle := &hiveserver2.TOperationHandle{
+		OperationId:   id,
+		OperationType: hiveserver2.TOperationType_EXECUTE_STATEMENT,
+		HasResultSet:  len(r.Columns) > 0,
+	}
+	if r.RowsAffected != nil {
+		n := float64(*r.RowsAffected)
+		handle.ModifiedRowCount = &n
+	}
+	return &hiveserver2.TExecuteStatementResp{Status: successStatus(), OperationHandle: handle}, nil
+}
+
+func (s *service) GetOperationStatus(ctx context.Context, req *hiveserver2.TGetOperationStatusReq) (*hiveserver2.TGetOperationStatusResp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, status := s.lookupOperation(req.OperationHandle)
+	if status != nil {
+		return &hiveserver2.TGetOperationStatusResp{Status: status}, nil
+	}
+	r := op.result
+	if op.state == hiveserver2.TOperationState_RUNNING_STATE {
+		if op.polls < r.Running {
+			op.polls++
+		} else {
+			op.state = r.finalState()
+		}
+	}
+
+	resp := hiveserver2.NewTGetOperationStatusResp()
+	resp.Status = successStatus()
+	state := op.state
+	resp.OperationState = &state
+	hasResultSet := len(r.Columns) > 0
+	resp.HasResultSet = &hasResultSet
+	if state == hiveserver2.TOperationState_ERROR_STATE && r.Error != "" {
+		resp.ErrorMessage = &r.Error
+	}
+	if req.GetGetProgressUpdate() && len(r.Progress) > 0 {
+		progress := &hiveserver2.TProgressUpdateResp{
+			HeaderNames:          []string{},
+			Rows:                 [][]string{},
+			ProgressedPercentage: 1,
+			Status:               hiveserver2.TJobExecutionStatus_COMPLETE,
+		}
+		if state == hiveserver2.TOperationState_RUNNING_STATE {
+			i := op.polls - 1
+			if i >= len(r.Progress) {
+				i = len(r.Progress) - 1
+			}
+			progress.ProgressedPercentage = r.Progress[i]
+			progress.Status = hiveserver2.TJobExecutionStatus_IN_PROGRESS
+		}
+		resp.ProgressUpdateResponse = progress
+	}
+	return resp, nil
+}
+
+func (s *service) CancelOperation(ctx context.Context, req *hiveserver2.TCancelOperationReq) (*hiveserver2.TCancelOperationResp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, status := s.lookupOperation(req.OperationHandle)
+	if status != nil {
+		return &hiveserver2.TCancelOperationResp{Status: status}, nil
+	}
+	op.state = hiveserver2.TOperationState_CANCELED_STATE
+	return &hiveserver2.TCancelOperationResp{Status: successStatus()}, nil
+}
+
+func (s *service) CloseOperation(ctx context.Context, req *hiveserver2.TCloseOperationReq) (*hiveserver2.TCloseOperationResp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, status := s.lookupOperation(req.OperationHandle); status != nil {
+		return &hiveserver2.TCloseOperationResp{Status: status}, nil
+	}
+	delete(s.operations, string(req.OperationHandle.OperationId.GUID))
+	return &hiveserver2.TCloseOperationResp{Status: successStatus()}, nil
+}
+
+func (s *service) GetResultSetMetadata(ctx context.Context, req *hiveserver2.TGetResultSetMetadataReq) (*hiveserver2.TGetResultSetMetadataResp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, status := s.lookupOperation(req.OperationHandle)
+	if status != nil {
+		return &hiveserver2.TGetResultSetMetadataResp{Status: status}, nil
+	}
+	return &hiveserver2.TGetResultSetMetadataResp{Status: successStatus(), Schema: op.result.schema()}, nil
+}
+
+func (s *service) FetchResults(ctx context.Context, req *hiveserver2.TFetchResultsReq) (*hiveserver2.TFetchResultsResp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, status := s.lookupOperation(req.OperationHandle)
+	if status != nil {
+		return &hiveserver2.TFetchResultsResp{Status: status}, nil
+	}
+	r := op.result
+	hasMoreRows := false
+	resp := &hiveserver2.TFetchResultsResp{Status: successStatus(), HasMoreRows: &hasMoreRows}
+
+	// FetchType 1 reads the operation log, returned as a single string column.
+	if req.FetchType == 1 {
+		logs := r.Logs[op.logs:]
+		op.logs = len(r.Logs)
+		logResult := &Result{Columns: []Column{{Name: "operation_log", Type: hiveserver2.TTypeId_STRING_TYPE}}}
+		rows := make([][]interface{}, len(logs))
+		for i, l := range logs {
+			rows[i] = []interface{}{l}
+		}
+		resp.Results = s.rowSet(logResult, rows, 0)
+		return resp, nil
+	}
+
+	if op.state != hiveserver2.TOperationState_FINISHED_STATE {
+		resp.Status = errorStatus("Expected state FINISHED, but found %s", op.state)
+		return resp, nil
+	}
+	start := op.fetched
+	end := len(r.Rows)
+	if req.MaxRows > 0 && int64(end-start) > req.MaxRows {
+		end = start + int(req.MaxRows)
+	}
+	op.fetched = end
+	hasMoreRows = end < len(r.Rows)
+	resp.Results = s.rowSet(r, r.Rows[start:end], start)
+	return resp, nil
+}
+
+func (s *service) rowSet(r *Result, rows [][]interface{}, offset int) *hiveserver2.TRowSet {
+	rs := &hiveserver2.TRowSet{StartRowOffset: int64(offset), Rows: []*hiveserver2.TRow{}}
+	if s.rowBased {
+		rs.Rows = r.rows(rows)
+	} else {
+		rs.Columns = r.columns(rows)
+	}
+	return rs
+}
+
+func (s *service) GetQueryId(ctx context.Context, req *hiveserver2.TGetQueryIdReq) (*hiveserver2.TGetQueryIdResp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, status := s.lookupOperation(req.OperationHandle)
+	if status != nil {
+		return nil, fmt.Errorf("%s", status.GetErrorMessage())
+	}
+	if op.result.QueryID != "" {
+		return &hiveserver2.TGetQueryIdResp{QueryId: op.result.QueryID}, nil
+	}
+	return &hiveserver2.TGetQueryIdResp{QueryId: fmt.Sprintf("hive_%x", req.OperationHandle.OperationId.GUID[:8])}, nil
+}
+
+func (s *service) SetClientInfo(ctx context.Context, req *hiveserver2.TSetClientInfoReq) (*hiveserver2.TSetClientInfoResp, error) {
+	return &hiveserver2.TSetClientInfoResp{Status: successStatus()}, nil
+}
+
+// The metadata and delegation token calls are not implemented.
+
+func (s *service) GetTypeInfo(ctx context.Context, req *hiveserver2.TGetTypeInfoReq) (*hiveserver2.TGetTypeInfoResp, error) {
+	return &hiveserver2.TGetTypeInfoResp{Status: notImplemented("GetTypeInfo")}, nil
+}
+
+func (s *service) GetCatalogs(ctx context.Context, req *hiveserver2.TGetCatalogsReq) (*hiveserver2.TGetCatalogsResp, error) {
+	return &hiveserver2.TGetCatalogsResp{Status: notImplemented("GetCatalogs")}, nil
+}
+
+func (s *service) GetSchemas(ctx context.Context, req *hiveserver2.TGetSchemasReq) (*hiveserver2.TGetSchemasResp, error) {
+	return &hiveserver2.TGetSchemasResp{Status: notImplemented("GetSchemas")}, nil
+}
+
+func (s *service) GetTables(ctx context.Context, req *hiveserver2.TGetTablesReq) (*hiveserver2.TGetTablesResp, error) {
+	return &hiveserver2.TGetTablesResp{Status: notImplemented("GetTables")}, nil
+}
+
+func (s *service) GetTableTypes(ctx context.Context, req *hiveserver2.TGetTableTypesReq) (*hiveserver2.TGetTableTypesResp, error) {
+	return &hiveserver2.TGetTableTypesResp{Status: notImplemented("GetTableTypes")}, nil
+}
+
+func (s *service) GetColumns(ctx context.Context, req *hiveserver2.TGetColumnsReq) (*hiveserver2.TGetColumnsResp, error) {
+	return &hiveserver2.TGetColumnsResp{Status: notImplemented("GetColumns")}, nil
+}
+
+func (s *service) GetFunctions(ctx context.Context, req *hiveserver2.TGetFunctionsReq) (*hiveserver2.TGetFunctionsResp, error) {
+	return &hiveserver2.TGetFunctionsResp{Status: notImplemented("GetFunctions")}, nil
+}
+
+func (s *service) GetPrimaryKeys(ctx context.Context, req *hiveserver2.TGetPrimaryKeysReq) (*hiveserver2.TGetPrimaryKeysResp, error) {
+	return &hiveserver2.TGetPrimaryKeysResp{Status: notImplemented("GetPrimaryKeys")}, nil
+}
+
+func (s *service) GetCrossReference(ctx context.Context, req *hiveserver2.TGetCrossReferenceReq) (*hiveserver2.TGetCrossReferenceResp, error) {
+	return &hiveserver2.TGetCrossReferenceResp{Status: notImplemented("GetCrossReference")}, nil
+}
+
+func (s *service) GetDelegationToken(ctx context.Context, req *hiveserver2.TGetDelegationTokenReq) (*hiveserver2.TGetDelegationTokenResp, error) {
+	return &hiveserver2.TGetDelegationTokenResp{Status: notImplemented("GetDelegationToken")}, nil
+}
+
+func (s *service) CancelDelegationToken(ctx context.Context, req *hiveserver2.TCancelDelegationTokenReq) (*hiveserver2.TCancelDelegationTokenResp, error) {
+	return &hiveserver2.TCancelDelegationTokenResp{Status: notImplemented("CancelDelegationToken")}, nil
+}
+
+func (s *service) RenewDelegationToken(ctx context.Context, req *hiveserver2.TRenewDelegationTokenReq) (*hiveserver2.TRenewDelegationTokenResp, error) {
+	return &hiveserver2.TRenewDelegationTokenResp{Status: notImplemented("RenewDelegationToken")}, nil
+}
+
+func notImplemented(method string) *hiveserver2.TStatus {
+	return errorStatus("hivetest: %s is not implemented", method)
+}
+
+var _ hiveserver2.TCLIService = (*service)(nil)
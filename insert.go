@@ -0,0 +1,341 @@
+package gohive
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default batch limits of BulkInsert.
+const (
+	DefaultInsertBatchRows  = 1000
+	DefaultInsertBatchBytes = 1 << 20
+)
+
+// InsertOptions controls BulkInsert.
+type InsertOptions struct {
+	// Columns lists the columns the values of each row go to. If empty,
+	// rows hold values for all non-partition columns of the table in order.
+	Columns []string
+	// Partition holds the values of static partition columns, written as
+	// PARTITION (k='v', ...).
+	Partition map[string]interface{}
+	// BatchRows is the maximum number of rows per INSERT statement,
+	// DefaultInsertBatchRows if zero.
+	BatchRows int
+	// BatchBytes is the maximum size of an INSERT statement,
+	// DefaultInsertBatchBytes if zero. A row that alone exceeds it is
+	// inserted by a statement of its own.
+	BatchBytes int
+	// ContinueOnError inserts the remaining batches after a failure
+	// instead of stopping at the first one.
+	ContinueOnError bool
+	// Progress, if set, is called after each batch.
+	Progress func(BatchResult)
+}
+
+// BatchResult reports the outcome of one INSERT statement of BulkInsert.
+type BatchResult struct {
+	// Offset is the index of the first row of the batch among all rows.
+	Offset int64
+	Rows   int
+	// Bytes is the size of the statement.
+	Bytes    int
+	Duration time.Duration
+	Err      error
+}
+
+// BulkInsert inserts rows into table with multi-row INSERT ... VALUES
+// statements, each of which runs as a single Hive job. Rows are grouped
+// into batches bounded by InsertOptions.BatchRows and BatchBytes, and
+// values are rendered as Hive literals: strings, []byte and time.Time are
+// quoted, and nil is NULL. A row of the wrong width or with a value that
+// cannot be rendered stops the insert, after the rows buffered before it are
+// inserted. It returns a result for every batch attempted, and the first
+// error encountered.
+func BulkInsert(ctx context.Context, conn *sql.Conn, table string, rows [][]interface{}, opts InsertOptions) ([]BatchResult, error) {
+	i := 0
+	return bulkInsert(ctx, conn, table, opts, func() ([]interface{}, bool) {
+		if i == len(rows) {
+			return nil, false
+		}
+		i++
+		return rows[i-1], true
+	})
+}
+
+// BulkInsertChan is like BulkInsert for rows received from a channel, until
+// it is closed or ctx is done. A partial batch is inserted as soon as the
+// channel is closed, and dropped with ctx.Err() returned if ctx is done. The
+// channel is not drained if BulkInsertChan stops on
+// an error, so producers should give up when ctx is done.
+func BulkInsertChan(ctx context.Context, conn *sql.Conn, table string, rows <-chan []interface{}, opts InsertOptions) ([]BatchResult, error) {
+	return bulkInsert(ctx, conn, table, opts, func() ([]interface{}, bool) {
+		select {
+		case row, ok := <-rows:
+			return row, ok
+		case <-ctx.Done():
+			return nil, false
+		}
+	})
+}
+
+func bulkInsert(ctx context.Context, conn *sql.Conn, table string, opts InsertOptions, next func() ([]interface{}, bool)) ([]BatchResult, error) {
+	if opts.BatchRows <= 0 {
+		opts.BatchRows = DefaultInsertBatchRows
+	}
+	if opts.BatchBytes <= 0 {
+		opts.BatchBytes = DefaultInsertBatchBytes
+	}
+	prefix, err := insertPrefix(table, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []BatchResult
+	var firstErr error
+	var stmt strings.Builder
+	var offset, count int64
+	n := 0
+	flush := func() bool {
+		if n == 0 {
+			return true
+		}
+		start := time.Now()
+		res := BatchResult{Offset: offset, Rows: n, Bytes: stmt.Len()}
+		_, res.Err = conn.ExecContext(ctx, stmt.String())
+		res.Duration = time.Since(start)
+		results = append(results, res)
+		if opts.Progress != nil {
+			opts.Progress(res)
+		}
+		offset += int64(n)
+		n = 0
+		stmt.Reset()
+		if res.Err != nil && firstErr == nil {
+			firstErr = res.Err
+		}
+		return res.Err == nil || opts.ContinueOnError && ctx.Err() == nil
+	}
+
+	width := len(opts.Columns)
+	for {
+		row, ok := next()
+		if !ok {
+			break
+		}
+		if width == 0 {
+			width = len(row)
+		}
+		if len(row) != width {
+			flush()
+			return results, fmt.Errorf("row %d has %d values, expected %d", count, len(row), width)
+		}
+		values, err := insertValues(row)
+		if err != nil {
+			flush()
+			return results, fmt.Errorf("row %d: %v", count, err)
+		}
+		count++
+		if n > 0 && stmt.Len()+len(", ")+len(values) > opts.BatchBytes {
+			if !flush() {
+				return results, firstErr
+			}
+		}
+		if n == 0 {
+			stmt.WriteString(prefix)
+		} else {
+			stmt.WriteString(", ")
+		}
+		stmt.WriteString(values)
+		n++
+		if n >= opts.BatchRows {
+			if !flush() {
+				return results, firstErr
+			}
+		}
+	}
+	if firstErr == nil && ctx.Err() != nil {
+		return results, ctx.Err()
+	}
+	flush()
+	return results, firstErr
+}
+
+// insertPrefix renders the INSERT statement up to the first row.
+func insertPrefix(table string, opts InsertOptions) (string, error) {
+	partition, err := partitionClause(opts.Partition)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString("INSERT INTO TABLE " + quoteTableName(table) + partition)
+	if len(opts.Columns) > 0 {
+		for i, c := range opts.Columns {
+			if i == 0 {
+				b.WriteString(" (")
+			} else {
+				b.WriteString(", ")
+			}
+			b.WriteString(quoteIdentifier(c))
+		}
+		b.WriteByte(')')
+	}
+	b.WriteString(" VALUES ")
+	return b.String(), nil
+}
+
+// partitionClause renders a static partition specification, preceded by a
+// space, or returns "" if partition is empty.
+func partitionClause(partition map[string]interface{}) (string, error) {
+	keys := make([]string, 0, len(partition))
+	for k := range partition {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		v, err := hiveLiteral(partition[k])
+		if err != nil {
+			return "", fmt.Errorf("partition %s: %v", k, err)
+		}
+		keys[i] = quoteIdentifier(k) + "=" + v
+	}
+	if len(keys) == 0 {
+		return "", nil
+	}
+	return " PARTITION (" + strings.Join(keys, ", ") + ")", nil
+}
+
+// quoteTableName quotes a table name, which may be qualified by the
+// database name.
+func quoteTableName(table string) string {
+	parts := strings.Split(table, ".")
+	for i, p := range parts {
+		parts[i] = quoteIdentifier(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// insertValues renders a row as a parenthesized list of literals.
+func insertValues(row []interface{}) (string, error) {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i, v := range row {
+		lit, err := hiveLiteral(v)
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(lit)
+	}
+	b.WriteByte(')')
+	return b.String(), nil
+}
+
+func quoteIdentifier(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+// hiveLiteral renders v as a Hive SQL literal.
+func hiveLiteral(v interface{}) (string, error) {
+	if valuer, ok := v.(driver.Valuer); ok {
+		var err error
+		if v, err = valuer.Value(); err != nil {
+			return "", err
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return quoteString(v), nil
+	case []byte:
+		return quoteString(string(v)), nil
+	case time.Time:
+		return quoteString(v.Format(hiveTimestampLayout)), nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return floatLiteral(float64(v), 32), nil
+	case float64:
+		return floatLiteral(v, 64), nil
+	}
+	return "", fmt.Errorf("unsupported value type %T", v)
+}
+
+func floatLiteral(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "CAST('NaN' AS DOUBLE)"
+	case math.IsInf(f, 1):
+		return "CAST('Infinity' AS DOUBLE)"
+	case math.IsInf(f, -1):
+		return "CAST('-Infinity' AS DOUBLE)"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+// quoteString quotes s as a Hive string literal. Hive unescapes backslash
+// sequences in literals, and "${" is escaped so that neither the driver nor
+// hiveserver2 substitute variables in values.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case 0:
+			b.WriteString(`\0`)
+		case '{':
+			if i > 0 && s[i-1] == '$' {
+				b.WriteString(`\{`)
+			} else {
+				b.WriteByte(c)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
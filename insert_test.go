@@ -0,0 +1,200 @@
+package gohive
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func TestHiveLiteral(t *testing.T) {
+	for _, tc := range []struct {
+		value   interface{}
+		literal string
+	}{
+		{nil, "NULL"},
+		{"it's", `'it\'s'`},
+		{"a\\b\n\t\r\x00", `'a\\b\n\t\r\0'`},
+		{"${hivevar:x} $y {z}", `'$\{hivevar:x} $y {z}'`},
+		{[]byte("bin"), "'bin'"},
+		{true, "TRUE"},
+		{int8(-3), "-3"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{float32(0.1), "0.1"},
+		{1e21, "1e+21"},
+		{math.NaN(), "CAST('NaN' AS DOUBLE)"},
+		{math.Inf(-1), "CAST('-Infinity' AS DOUBLE)"},
+		{time.Date(2023, 4, 5, 6, 7, 8, 9e8, time.UTC), "'2023-04-05 06:07:08.9'"},
+		{sql.NullInt64{Int64: 7, Valid: true}, "7"},
+		{sql.NullString{}, "NULL"},
+	} {
+		lit, err := hiveLiteral(tc.value)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.literal, lit)
+	}
+	_, err := hiveLiteral([]int{1})
+	assert.Error(t, err)
+}
+
+func TestInsertPrefix(t *testing.T) {
+	prefix, err := insertPrefix("db.pred`s", InsertOptions{
+		Columns:   []string{"id", "score"},
+		Partition: map[string]interface{}{"region": "eu", "dt": "2023-04-05"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO TABLE `db`.`pred``s` PARTITION (`dt`='2023-04-05', `region`='eu') (`id`, `score`) VALUES ", prefix)
+}
+
+func openInsertConn(t *testing.T, srv *hivetest.Server) *sql.Conn {
+	db, err := sql.Open("hive", srv.DSN()+"&pollInterval=1ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestBulkInsert(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.HandleFunc(func(stmt string) *hivetest.Result { return &hivetest.Result{} })
+	conn := openInsertConn(t, srv)
+
+	var progress []int64
+	rows := [][]interface{}{{1, "a"}, {2, "b"}, {3, strings.Repeat("c", 40)}, {4, nil}, {5, "e"}}
+	results, err := BulkInsert(context.Background(), conn, "t", rows, InsertOptions{
+		BatchRows:  2,
+		BatchBytes: 60,
+		Progress:   func(r BatchResult) { progress = append(progress, r.Offset+int64(r.Rows)) },
+	})
+	a.NoError(err)
+	a.Equal([]int64{2, 3, 5}, progress)
+	a.Len(results, 3)
+	var stmts []string
+	for _, s := range srv.Statements() {
+		stmts = append(stmts, s.Statement)
+	}
+	a.Equal([]string{
+		"INSERT INTO TABLE `t` VALUES (1, 'a'), (2, 'b')",
+		"INSERT INTO TABLE `t` VALUES (3, '" + strings.Repeat("c", 40) + "')",
+		"INSERT INTO TABLE `t` VALUES (4, NULL), (5, 'e')",
+	}, stmts)
+	a.Equal(BatchResult{Offset: 2, Rows: 1, Bytes: len(stmts[1]), Duration: results[1].Duration}, results[1])
+
+	results, err = BulkInsert(context.Background(), conn, "t", [][]interface{}{{1, 2}, {3}}, InsertOptions{})
+	a.Error(err)
+	a.Len(results, 1)
+	a.Equal("INSERT INTO TABLE `t` VALUES (1, 2)", srv.Statements()[3].Statement)
+}
+
+func TestBulkInsertChanCancel(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.HandleFunc(func(stmt string) *hivetest.Result { return &hivetest.Result{} })
+	conn := openInsertConn(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan []interface{})
+	go func() {
+		ch <- []interface{}{1}
+		cancel()
+	}()
+	results, err := BulkInsertChan(ctx, conn, "t", ch, InsertOptions{})
+	a.Equal(context.Canceled, err)
+	a.Empty(results)
+	a.Empty(srv.Statements())
+}
+
+func TestBulkInsertChanErrors(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.HandleFunc(func(stmt string) *hivetest.Result {
+		if strings.Contains(stmt, "'bad'") {
+			return &hivetest.Result{Error: "cannot convert"}
+		}
+		return &hivetest.Result{}
+	})
+	conn := openInsertConn(t, srv)
+
+	send := func() <-chan []interface{} {
+		ch := make(chan []interface{}, 4)
+		ch <- []interface{}{"bad"}
+		ch <- []interface{}{"ok"}
+		ch <- []interface{}{"ok"}
+		close(ch)
+		return ch
+	}
+	opts := InsertOptions{BatchRows: 1}
+	results, err := BulkInsertChan(context.Background(), conn, "t", send(), opts)
+	a.Error(err)
+	a.Len(results, 1)
+	a.Equal(err, results[0].Err)
+
+	opts.ContinueOnError = true
+	results, err = BulkInsertChan(context.Background(), conn, "t", send(), opts)
+	a.Error(err)
+	a.Len(results, 3)
+	a.Error(results[0].Err)
+	a.NoError(results[1].Err)
+	a.NoError(results[2].Err)
+}
+
+func TestPreparedStatement(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.HandleFunc(func(stmt string) *hivetest.Result { return &hivetest.Result{} })
+	conn := openInsertConn(t, srv)
+
+	stmt, err := conn.PrepareContext(context.Background(), "INSERT INTO t VALUES (1)")
+	a.NoError(err)
+	_, err = stmt.Exec(ConfOverlay{"mapreduce.job.queuename": "etl"})
+	a.NoError(err)
+	a.NoError(stmt.Close())
+	a.Equal(map[string]string{"mapreduce.job.queuename": "etl"}, srv.Statements()[0].ConfOverlay)
+
+	stmt, err = conn.PrepareContext(context.Background(), "INSERT INTO t VALUES (?, ?)")
+	a.NoError(err)
+	_, err = stmt.Exec(2, "it's")
+	a.NoError(err)
+	_, err = stmt.Exec(3)
+	a.Error(err)
+	a.NoError(stmt.Close())
+	a.Len(srv.Statements(), 2)
+	a.Equal(`INSERT INTO t VALUES (2, 'it\'s')`, srv.Statements()[1].Statement)
+}
+
+func TestBindArgs(t *testing.T) {
+	a := assert.New(t)
+	args := func(values ...driver.Value) []driver.NamedValue { return namedValues(values) }
+
+	q, err := bindArgs("SELECT ?", nil)
+	a.NoError(err)
+	a.Equal("SELECT ?", q)
+	q, err = bindArgs("SELECT '?', `a?`, ? -- ?\n/* ? */ FROM t WHERE x = ?", args(1, ConfOverlay{"a": "b"}, nil))
+	a.NoError(err)
+	a.Equal("SELECT '?', `a?`, 1 -- ?\n/* ? */ FROM t WHERE x = NULL", q)
+
+	_, err = bindArgs("SELECT ?", args(1, 2))
+	a.Error(err)
+	_, err = bindArgs("SELECT ?, ?", args(1))
+	a.Error(err)
+	_, err = bindArgs("SELECT ?", args([]int{1}))
+	a.Error(err)
+	_, err = bindArgs("SELECT ?", []driver.NamedValue{{Name: "x", Ordinal: 1, Value: int64(1)}})
+	a.Error(err)
+}
@@ -0,0 +1,225 @@
+package gohive
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	jdbcPrefix  = "jdbc:hive2://"
+	hive2Prefix = "hive2://"
+
+	httpTransportMode = "http"
+
+	// defaultJDBCPort is the port of hosts listed without one, as in the
+	// Hive JDBC driver.
+	defaultJDBCPort = "10000"
+)
+
+// JDBC session variables that map onto Config fields. Others are kept in
+// Config.JDBCParams.
+const (
+	jdbcUser          = "user"
+	jdbcPassword      = "password"
+	jdbcAuth          = "auth"
+	jdbcNoSasl        = "noSasl"
+	jdbcPrincipal     = "principal"
+	jdbcTransportMode = "transportMode"
+	jdbcHTTPPath      = "httpPath"
+	jdbcSSL           = "ssl"
+	jdbcFetchSize     = "fetchSize"
+
+	jdbcServiceDiscoveryMode = "serviceDiscoveryMode"
+	jdbcZooKeeperNamespace   = "zooKeeperNamespace"
+)
+
+// isJDBC reports whether dsn is a Hive JDBC URL.
+func isJDBC(dsn string) bool {
+	return strings.HasPrefix(dsn, jdbcPrefix) || strings.HasPrefix(dsn, hive2Prefix)
+}
+
+// ParseJDBC parses a Hive JDBC URL in the format
+//
+//	jdbc:hive2://host1:port1[,host2:port2...]/[dbname][;sess_var_list][?hive_conf_list][#hive_var_list]
+//
+// where each list is made of key=value pairs separated by semicolons. The
+// leading "jdbc:" is optional. Hosts without a port use port 10000, and
+// multiple hosts are tried in order when connecting. Kerberos, enabled by
+// the principal variable, is not supported in the http transport mode,
+// which would need SPNEGO, and neither is ZooKeeper service discovery. Session variables that gohive does not interpret are kept in
+// Config.JDBCParams, so that FormatJDBC can reproduce them.
+func ParseJDBC(url string) (*Config, error) {
+	rest := strings.TrimPrefix(url, "jdbc:")
+	if !strings.HasPrefix(rest, hive2Prefix) {
+		return nil, fmt.Errorf("The JDBC URL %s doesn't start with %s", url, jdbcPrefix)
+	}
+	rest = rest[len(hive2Prefix):]
+
+	cfg := &Config{
+		Auth:       "PLAIN",
+		Batch:      defaultBatchSize,
+		SessionCfg: make(map[string]string),
+		HiveVars:   make(map[string]string),
+		HiveConf:   make(map[string]string),
+		JDBCParams: make(map[string]string),
+	}
+	var err error
+	if i := strings.IndexByte(rest, '#'); i >= 0 {
+		if cfg.HiveVars, err = parseJDBCList(rest[i+1:]); err != nil {
+			return nil, err
+		}
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		if cfg.HiveConf, err = parseJDBCList(rest[i+1:]); err != nil {
+			return nil, err
+		}
+		rest = rest[:i]
+	}
+	sessVars := map[string]string{}
+	if i := strings.IndexByte(rest, ';'); i >= 0 {
+		if sessVars, err = parseJDBCList(rest[i+1:]); err != nil {
+			return nil, err
+		}
+		rest = rest[:i]
+	}
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		cfg.DBName = rest[i+1:]
+		rest = rest[:i]
+	}
+	if rest == "" {
+		return nil, fmt.Errorf("The JDBC URL %s has no host, embedded mode is not supported", url)
+	}
+	if strings.EqualFold(sessVars[jdbcServiceDiscoveryMode], "zooKeeper") || sessVars[jdbcZooKeeperNamespace] != "" {
+		return nil, fmt.Errorf("The JDBC URL %s uses ZooKeeper service discovery, which is not supported", url)
+	}
+	hosts := strings.Split(rest, ",")
+	for i, h := range hosts {
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			hosts[i] = net.JoinHostPort(strings.Trim(h, "[]"), defaultJDBCPort)
+		}
+	}
+	cfg.Addr = strings.Join(hosts, ",")
+
+	for k, v := range sessVars {
+		switch k {
+		case jdbcUser:
+			cfg.User = v
+		case jdbcPassword:
+			cfg.Passwd = v
+		case jdbcPrincipal:
+			cfg.Principal = v
+			cfg.Auth = "GSSAPI"
+		case jdbcTransportMode:
+			cfg.TransportMode = v
+		case jdbcHTTPPath:
+			cfg.HTTPPath = v
+		case jdbcSSL:
+			if v == "true" {
+				cfg.TLSConfig = &tls.Config{}
+			}
+		case jdbcFetchSize:
+			if cfg.Batch, err = strconv.Atoi(v); err != nil {
+				return nil, err
+			}
+		case jdbcAuth:
+			if v != jdbcNoSasl {
+				cfg.JDBCParams[k] = v
+			}
+		default:
+			cfg.JDBCParams[k] = v
+		}
+	}
+	if sessVars[jdbcAuth] == jdbcNoSasl {
+		cfg.Auth = "NOSASL"
+	}
+	if cfg.Auth == "GSSAPI" && cfg.TransportMode == httpTransportMode {
+		return nil, fmt.Errorf("The JDBC URL %s uses Kerberos in http transport mode, which is not supported", url)
+	}
+	return cfg, nil
+}
+
+func parseJDBCList(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, kv := range strings.Split(s, ";") {
+		if kv == "" {
+			continue
+		}
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("The JDBC URL parameter %s is not in the format key=value", kv)
+		}
+		m[kv[:i]] = kv[i+1:]
+	}
+	return m, nil
+}
+
+// FormatJDBC outputs cfg as a Hive JDBC URL that ParseJDBC can read back.
+// Both SessionCfg and HiveConf are written to the hive_conf_list, and keys
+// are sorted so that the output is deterministic.
+func FormatJDBC(cfg *Config) string {
+	url := jdbcPrefix + cfg.Addr + "/" + cfg.DBName
+
+	sessVars := make(map[string]string)
+	for k, v := range cfg.JDBCParams {
+		sessVars[k] = v
+	}
+	if cfg.User != "" {
+		sessVars[jdbcUser] = cfg.User
+	}
+	if cfg.Passwd != "" {
+		sessVars[jdbcPassword] = cfg.Passwd
+	}
+	if cfg.Principal != "" {
+		sessVars[jdbcPrincipal] = cfg.Principal
+	}
+	if cfg.Auth == "NOSASL" {
+		sessVars[jdbcAuth] = jdbcNoSasl
+	}
+	if cfg.TransportMode != "" {
+		sessVars[jdbcTransportMode] = cfg.TransportMode
+	}
+	if cfg.HTTPPath != "" {
+		sessVars[jdbcHTTPPath] = cfg.HTTPPath
+	}
+	if cfg.TLSConfig != nil {
+		sessVars[jdbcSSL] = "true"
+	}
+	if cfg.Batch != defaultBatchSize && cfg.Batch > 0 {
+		sessVars[jdbcFetchSize] = strconv.Itoa(cfg.Batch)
+	}
+	if len(sessVars) > 0 {
+		url += ";" + formatJDBCList(sessVars)
+	}
+
+	conf := make(map[string]string)
+	for k, v := range cfg.SessionCfg {
+		conf[k] = v
+	}
+	for k, v := range cfg.HiveConf {
+		conf[k] = v
+	}
+	if len(conf) > 0 {
+		url += "?" + formatJDBCList(conf)
+	}
+	if len(cfg.HiveVars) > 0 {
+		url += "#" + formatJDBCList(cfg.HiveVars)
+	}
+	return url
+}
+
+func formatJDBCList(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		keys[i] = k + "=" + m[k]
+	}
+	return strings.Join(keys, ";")
+}
@@ -0,0 +1,64 @@
+package gohive
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJDBC(t *testing.T) {
+	a := assert.New(t)
+	url := "jdbc:hive2://host1:10000,host2/db;principal=hive/_HOST@REALM?hive.exec.parallel=true#var=1"
+	cfg, err := ParseDSN(url)
+	a.NoError(err)
+	a.Equal("host1:10000,host2:10000", cfg.Addr)
+	a.Equal("db", cfg.DBName)
+	a.Equal("GSSAPI", cfg.Auth)
+	a.Equal("hive/_HOST@REALM", cfg.Principal)
+	a.Equal(map[string]string{"hive.exec.parallel": "true"}, cfg.HiveConf)
+	a.Equal(map[string]string{"var": "1"}, cfg.HiveVars)
+	a.Equal(defaultBatchSize, cfg.Batch)
+
+	cfg, err = ParseDSN("jdbc:hive2://[::1]/db;user=u;transportMode=http;httpPath=cliservice")
+	a.NoError(err)
+	a.Equal("[::1]:10000", cfg.Addr)
+	a.Equal("PLAIN", cfg.Auth)
+	a.Equal("http", cfg.TransportMode)
+	a.Equal("cliservice", cfg.HTTPPath)
+
+	cfg, err = ParseJDBC("hive2://localhost:10000/;user=u;password=p=w;auth=noSasl;ssl=true;fetchSize=100;retries=3")
+	a.NoError(err)
+	a.Equal("", cfg.DBName)
+	a.Equal("u", cfg.User)
+	a.Equal("p=w", cfg.Passwd)
+	a.Equal("NOSASL", cfg.Auth)
+	a.NotNil(cfg.TLSConfig)
+	a.Equal(100, cfg.Batch)
+	a.Equal(map[string]string{"retries": "3"}, cfg.JDBCParams)
+
+	_, err = ParseJDBC("jdbc:hive2:///db")
+	a.Error(err)
+	_, err = ParseJDBC("jdbc:hive2://h:1/db;novalue")
+	a.Error(err)
+	_, err = ParseJDBC("jdbc:mysql://h:1/db")
+	a.Error(err)
+	_, err = ParseJDBC("jdbc:hive2://h:1/db;principal=hive/_HOST@REALM;transportMode=http")
+	a.Error(err)
+	_, err = ParseJDBC("jdbc:hive2://zk1:2181,zk2:2181/db;serviceDiscoveryMode=zooKeeper;zooKeeperNamespace=hiveserver2")
+	a.ErrorContains(err, "ZooKeeper service discovery")
+	_, err = ParseJDBC("jdbc:hive2://zk1:2181/;zooKeeperNamespace=hiveserver2")
+	a.Error(err)
+}
+
+func TestFormatJDBC(t *testing.T) {
+	a := assert.New(t)
+	for _, url := range []string{
+		"jdbc:hive2://host1:10000,host2:10000/db;principal=hive/_HOST@REALM?hive.exec.parallel=true#var=1",
+		"jdbc:hive2://h:10000/db;httpPath=cliservice;transportMode=http;user=u",
+		"jdbc:hive2://localhost:10000/;auth=noSasl;fetchSize=100;password=p;retries=3;ssl=true;user=u",
+	} {
+		cfg, err := ParseJDBC(url)
+		a.NoError(err)
+		a.Equal(url, FormatJDBC(cfg))
+	}
+}
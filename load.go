@@ -0,0 +1,448 @@
+package gohive
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// StageFormat writes the staging files of Load in a format that Hive reads,
+// which must match the storage format of the target table. TextFormat
+// matches STORED AS TEXTFILE tables, and hiveparquet.StageFormat matches
+// STORED AS PARQUET tables. Other formats, such as ORC, can be plugged in
+// by implementing StageFormat.
+type StageFormat interface {
+	// Ext is the file name extension of staging files, e.g. ".txt".
+	Ext() string
+	// Create creates or truncates the file at path.
+	Create(path string) (RowWriter, error)
+}
+
+// RowWriter writes rows to a staging file.
+type RowWriter interface {
+	WriteRow(row []interface{}) error
+	// Close completes and closes the file.
+	Close() error
+}
+
+// Uploader makes staging files available to hiveserver2. LocalUploader
+// shares files through the local filesystem and WebHDFSUploader copies them
+// to HDFS. Object stores can be supported by an Uploader that returns
+// paths like s3a://bucket/key.
+type Uploader interface {
+	// Upload copies the local file and returns the path LOAD DATA reads it
+	// from.
+	Upload(ctx context.Context, local string) (string, error)
+	// Remove removes an uploaded file. Load calls it whenever the file may
+	// remain after LOAD DATA, so it must not fail if the file is gone.
+	Remove(ctx context.Context, path string) error
+	// Local reports whether paths are on the filesystem of hiveserver2,
+	// to be loaded with LOAD DATA LOCAL, which copies the file instead of
+	// moving it.
+	Local() bool
+}
+
+// LoadOptions controls Load.
+type LoadOptions struct {
+	// Format of the staging file, TextFormat{} if nil.
+	Format StageFormat
+	// Uploader of the staging file, LocalUploader{} if nil.
+	Uploader Uploader
+	// StagingDir holds the local staging file, os.TempDir() if empty.
+	StagingDir string
+	// Partition holds the values of the partition columns to load into,
+	// written as PARTITION (k='v', ...).
+	Partition map[string]interface{}
+	// Overwrite replaces the data of the table or partition.
+	Overwrite bool
+}
+
+// Load writes rows to a staging file, uploads it and moves it into table
+// with LOAD DATA, which is much faster than INSERT for many rows. Values
+// are written by LoadOptions.Format, whose layout must match the storage
+// format and columns of the table, as Hive does not convert loaded files.
+// Staging and uploaded files are removed if Load fails. It returns the
+// number of rows loaded.
+func Load(ctx context.Context, conn *sql.Conn, table string, rows [][]interface{}, opts LoadOptions) (int64, error) {
+	i := 0
+	return load(ctx, conn, table, opts, func() ([]interface{}, bool) {
+		if i == len(rows) {
+			return nil, false
+		}
+		i++
+		return rows[i-1], true
+	})
+}
+
+// LoadChan is like Load for rows received from a channel, until it is
+// closed. If ctx is done first, nothing is loaded and the channel is not
+// drained.
+func LoadChan(ctx context.Context, conn *sql.Conn, table string, rows <-chan []interface{}, opts LoadOptions) (int64, error) {
+	return load(ctx, conn, table, opts, func() ([]interface{}, bool) {
+		select {
+		case row, ok := <-rows:
+			return row, ok
+		case <-ctx.Done():
+			return nil, false
+		}
+	})
+}
+
+func load(ctx context.Context, conn *sql.Conn, table string, opts LoadOptions, next func() ([]interface{}, bool)) (int64, error) {
+	if opts.Format == nil {
+		opts.Format = TextFormat{}
+	}
+	if opts.Uploader == nil {
+		opts.Uploader = LocalUploader{}
+	}
+	partition, err := partitionClause(opts.Partition)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.CreateTemp(opts.StagingDir, "gohive-*"+opts.Format.Ext())
+	if err != nil {
+		return 0, err
+	}
+	staged := f.Name()
+	f.Close()
+	defer os.Remove(staged)
+
+	n, err := stage(staged, opts.Format, next)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	uploaded, err := opts.Uploader.Upload(ctx, staged)
+	if err != nil {
+		return 0, err
+	}
+	stmt := "LOAD DATA "
+	if opts.Uploader.Local() {
+		stmt += "LOCAL "
+	}
+	stmt += "INPATH " + quoteString(uploaded) + " "
+	if opts.Overwrite {
+		stmt += "OVERWRITE "
+	}
+	stmt += "INTO TABLE " + quoteTableName(table) + partition
+	_, err = conn.ExecContext(ctx, stmt)
+	// LOAD DATA moves the file unless it is local, in which case it is
+	// copied.
+	if (err != nil || opts.Uploader.Local()) && uploaded != staged {
+		if e := opts.Uploader.Remove(context.Background(), uploaded); err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// stage writes the rows returned by next to the file at path.
+func stage(path string, format StageFormat, next func() ([]interface{}, bool)) (int64, error) {
+	w, err := format.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for {
+		row, ok := next()
+		if !ok {
+			break
+		}
+		if err := w.WriteRow(row); err != nil {
+			w.Close()
+			return n, fmt.Errorf("row %d: %w", n, err)
+		}
+		n++
+	}
+	return n, w.Close()
+}
+
+// TextFormat writes delimited text, as read by tables STORED AS TEXTFILE
+// with the default LazySimpleSerDe. Strings, []byte, time.Time, numbers
+// and booleans are supported. ARRAY, MAP and STRUCT values must be given
+// as text using the collection delimiters of the table.
+type TextFormat struct {
+	// Delimiter separates fields, '\x01' by default as in Hive.
+	Delimiter rune
+	// Null represents NULL, `\N` by default as in Hive.
+	Null string
+	// Escaped escapes the delimiter, line breaks and backslashes with a
+	// backslash, for tables created with ESCAPED BY '\\' and
+	// serialization.escape.crlf=true. Otherwise values are written as is,
+	// and values containing the delimiter or line breaks cannot be written.
+	Escaped bool
+}
+
+// Ext implements StageFormat.
+func (f TextFormat) Ext() string {
+	return ".txt"
+}
+
+// Create implements StageFormat.
+func (f TextFormat) Create(path string) (RowWriter, error) {
+	if f.Delimiter == 0 {
+		f.Delimiter = '\x01'
+	}
+	if f.Null == "" {
+		f.Null = `\N`
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(file)
+	return &textWriter{
+		file:   file,
+		w:      w,
+		format: f,
+		e:      &exporter{w: w, opts: ExportOptions{Delimiter: f.Delimiter, Null: f.Null}},
+	}, nil
+}
+
+type textWriter struct {
+	file   *os.File
+	w      *bufio.Writer
+	format TextFormat
+	// e formats values like Export.
+	e *exporter
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (w *textWriter) WriteRow(row []interface{}) error {
+	if len(w.e.types) != len(row) {
+		w.e.types = make([]string, len(row))
+	}
+	fields := make([]string, len(row))
+	for i, v := range row {
+		if v == nil {
+			continue
+		}
+		switch t := reflect.TypeOf(v); t.Kind() {
+		case reflect.Map, reflect.Array, reflect.Struct, reflect.Slice:
+			if _, ok := v.([]byte); !ok && t != timeType {
+				return fmt.Errorf("unsupported value type %T", v)
+			}
+		}
+		fields[i] = w.e.format(i, v)
+		if !w.format.Escaped {
+			if strings.ContainsAny(fields[i], "\r\n"+string(w.format.Delimiter)) || fields[i] == w.format.Null {
+				return fmt.Errorf("value %q contains the delimiter or a line break, or is the NULL representation", fields[i])
+			}
+		}
+	}
+	for i, v := range row {
+		if i > 0 {
+			w.w.WriteRune(w.format.Delimiter)
+		}
+		if v == nil {
+			w.w.WriteString(w.format.Null)
+		} else {
+			w.writeField(fields[i])
+		}
+	}
+	w.w.WriteByte('\n')
+	return nil
+}
+
+// writeField writes s as is, unless the table escapes special characters.
+// Without ESCAPED BY, Hive reads backslashes literally.
+func (w *textWriter) writeField(s string) {
+	if !w.format.Escaped {
+		w.w.WriteString(s)
+		return
+	}
+	for _, r := range s {
+		switch r {
+		case '\\', w.format.Delimiter:
+			w.w.WriteByte('\\')
+			w.w.WriteRune(r)
+		case '\n':
+			w.w.WriteString(`\n`)
+		case '\r':
+			w.w.WriteString(`\r`)
+		default:
+			w.w.WriteRune(r)
+		}
+	}
+}
+
+func (w *textWriter) Close() error {
+	err := w.w.Flush()
+	if e := w.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// LocalUploader shares staging files through a filesystem that
+// hiveserver2 can read, for LOAD DATA LOCAL.
+type LocalUploader struct {
+	// Dir is where hiveserver2 sees staging files, e.g. a shared mount.
+	// Files are used in place if empty, when hiveserver2 runs on the same
+	// host.
+	Dir string
+}
+
+// Upload implements Uploader.
+func (u LocalUploader) Upload(ctx context.Context, local string) (string, error) {
+	if u.Dir == "" {
+		return filepath.Abs(local)
+	}
+	dst := filepath.Join(u.Dir, filepath.Base(local))
+	if err := copyFile(dst, local); err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+	return filepath.Abs(dst)
+}
+
+// Remove implements Uploader.
+func (u LocalUploader) Remove(ctx context.Context, path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Local implements Uploader.
+func (u LocalUploader) Local() bool {
+	return true
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// WebHDFSUploader uploads staging files to HDFS through the WebHDFS REST
+// API of the NameNode.
+type WebHDFSUploader struct {
+	// URL of the NameNode, e.g. http://namenode:9870.
+	URL string
+	// Dir is the HDFS directory of uploaded files, which the hive user
+	// must be able to move files out of.
+	Dir string
+	// User is passed as user.name with simple authentication.
+	User string
+	// Client sends requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Upload implements Uploader. WebHDFS redirects the creation of a file to
+// a DataNode, which is followed manually as the data must be sent again.
+func (u WebHDFSUploader) Upload(ctx context.Context, local string) (string, error) {
+	dst := path.Join("/", u.Dir, filepath.Base(local))
+	client := *u.client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.opURL(dst, "CREATE", "overwrite=true"), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusTemporaryRedirect || location == "" {
+		return "", fmt.Errorf("WebHDFS CREATE %s: %s", dst, resp.Status)
+	}
+
+	f, err := os.Open(local)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	req, err = http.NewRequestWithContext(ctx, http.MethodPut, location, f)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if fi, err := f.Stat(); err == nil {
+		req.ContentLength = fi.Size()
+	}
+	resp, err = u.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		u.Remove(context.Background(), dst)
+		return "", fmt.Errorf("WebHDFS CREATE %s: %s", dst, resp.Status)
+	}
+	return dst, nil
+}
+
+// Remove implements Uploader.
+func (u WebHDFSUploader) Remove(ctx context.Context, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.opURL(path, "DELETE", ""), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.client().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("WebHDFS DELETE %s: %s", path, resp.Status)
+	}
+	return nil
+}
+
+// Local implements Uploader.
+func (u WebHDFSUploader) Local() bool {
+	return false
+}
+
+func (u WebHDFSUploader) client() *http.Client {
+	if u.Client != nil {
+		return u.Client
+	}
+	return http.DefaultClient
+}
+
+func (u WebHDFSUploader) opURL(path, op, params string) string {
+	q := url.Values{"op": {op}}
+	if u.User != "" {
+		q.Set("user.name", u.User)
+	}
+	s := strings.TrimSuffix(u.URL, "/") + "/webhdfs/v1" + (&url.URL{Path: path}).EscapedPath() + "?" + q.Encode()
+	if params != "" {
+		s += "&" + params
+	}
+	return s
+}
@@ -0,0 +1,127 @@
+package gohive
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func TestTextFormat(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "stage.txt")
+	w, err := TextFormat{}.Create(path)
+	a.NoError(err)
+	a.NoError(w.WriteRow([]interface{}{1, "a b", nil, 0.5, true, time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)}))
+	a.NoError(w.WriteRow([]interface{}{2, `C:\x`}))
+	a.Error(w.WriteRow([]interface{}{2, "a\nb"}))
+	a.Error(w.WriteRow([]interface{}{3, `\N`}))
+	a.Error(w.WriteRow([]interface{}{4, []int{1}}))
+	a.NoError(w.Close())
+	b, err := os.ReadFile(path)
+	a.NoError(err)
+	a.Equal("1\x01a b\x01\\N\x010.5\x01true\x012023-04-05 06:07:08\n2\x01C:\\x\n", string(b))
+
+	w, err = TextFormat{Delimiter: ',', Escaped: true}.Create(path)
+	a.NoError(err)
+	a.NoError(w.WriteRow([]interface{}{"a,b\nc\\d", nil}))
+	a.NoError(w.Close())
+	b, err = os.ReadFile(path)
+	a.NoError(err)
+	a.Equal(`a\,b\nc\\d,\N`+"\n", string(b))
+}
+
+func TestLoad(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	var loaded string
+	srv.HandleFunc(func(stmt string) *hivetest.Result {
+		start := strings.Index(stmt, "'") + 1
+		b, err := os.ReadFile(stmt[start : start+strings.Index(stmt[start:], "'")])
+		if err != nil {
+			return &hivetest.Result{Error: err.Error()}
+		}
+		loaded = string(b)
+		if strings.Contains(loaded, "fail") {
+			return &hivetest.Result{Error: "Invalid path"}
+		}
+		return &hivetest.Result{}
+	})
+	conn := openInsertConn(t, srv)
+
+	staging, shared := t.TempDir(), t.TempDir()
+	opts := LoadOptions{
+		Format:     TextFormat{Delimiter: ','},
+		Uploader:   LocalUploader{Dir: shared},
+		StagingDir: staging,
+		Partition:  map[string]interface{}{"dt": "2023-04-05"},
+		Overwrite:  true,
+	}
+	n, err := Load(context.Background(), conn, "db.t", [][]interface{}{{1, "a"}, {2, nil}}, opts)
+	a.NoError(err)
+	a.EqualValues(2, n)
+	a.Equal("1,a\n2,\\N\n", loaded)
+	stmt := srv.Statements()[0].Statement
+	a.Regexp("^LOAD DATA LOCAL INPATH '"+shared+"/gohive-[0-9]+\\.txt' OVERWRITE INTO TABLE `db`.`t` PARTITION \\(`dt`='2023-04-05'\\)$", stmt)
+
+	ch := make(chan []interface{}, 1)
+	ch <- []interface{}{"fail"}
+	close(ch)
+	_, err = LoadChan(context.Background(), conn, "t", ch, opts)
+	a.Error(err)
+
+	_, err = Load(context.Background(), conn, "t", [][]interface{}{{1}, {"a\nb"}}, opts)
+	a.Error(err)
+	a.Len(srv.Statements(), 2)
+
+	for _, dir := range []string{staging, shared} {
+		entries, err := os.ReadDir(dir)
+		a.NoError(err)
+		a.Empty(entries)
+	}
+}
+
+func TestWebHDFSUploader(t *testing.T) {
+	a := assert.New(t)
+	files := map[string]string{}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/webhdfs/v1")
+		q := r.URL.Query()
+		switch {
+		case r.Method == http.MethodPut && q.Get("datanode") == "":
+			a.Equal("CREATE", q.Get("op"))
+			a.Equal("hive", q.Get("user.name"))
+			http.Redirect(w, r, srv.URL+r.URL.Path+"?datanode=1", http.StatusTemporaryRedirect)
+		case r.Method == http.MethodPut:
+			b, _ := io.ReadAll(r.Body)
+			files[path] = string(b)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodDelete:
+			a.Equal("DELETE", q.Get("op"))
+			delete(files, path)
+			w.Write([]byte(`{"boolean": true}`))
+		}
+	}))
+	defer srv.Close()
+
+	local := filepath.Join(t.TempDir(), "stage.txt")
+	a.NoError(os.WriteFile(local, []byte("1,a\n"), 0644))
+	u := WebHDFSUploader{URL: srv.URL, Dir: "/tmp/staging", User: "hive"}
+	a.False(u.Local())
+	path, err := u.Upload(context.Background(), local)
+	a.NoError(err)
+	a.Equal("/tmp/staging/stage.txt", path)
+	a.Equal(map[string]string{"/tmp/staging/stage.txt": "1,a\n"}, files)
+	a.NoError(u.Remove(context.Background(), path))
+	a.Empty(files)
+}
@@ -0,0 +1,140 @@
+package gohive
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+// ErrQueryTimeout is wrapped by the OperationError of queries that
+// hiveserver2 cancelled because they exceeded their query timeout.
+var ErrQueryTimeout = errors.New("query timed out")
+
+// OperationError is returned when a Hive operation fails. It carries the
+// Hive query ID, which locates the query in Tez UI or YARN, and the
+// identifier of the hiveserver2 operation.
+type OperationError struct {
+	QueryID     string
+	OperationID string
+	Err         error
+}
+
+func (e *OperationError) Error() string {
+	if e.QueryID == "" {
+		return fmt.Sprintf("operation %s: %v", e.OperationID, e.Err)
+	}
+	return fmt.Sprintf("query %s (operation %s): %v", e.QueryID, e.OperationID, e.Err)
+}
+
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// operationID formats TOperationHandle.OperationId as a UUID, which is how
+// hiveserver2 prints operation handles in its own logs.
+func operationID(op *hiveserver2.TOperationHandle) string {
+	if op == nil || op.OperationId == nil {
+		return ""
+	}
+	g := op.OperationId.GUID
+	if len(g) != 16 {
+		return hex.EncodeToString(g)
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", g[0:4], g[4:6], g[6:8], g[8:10], g[10:16])
+}
+
+// Operation is a statement running asynchronously on hiveserver2. Its
+// operation logs can be fetched while it runs and after it finishes.
+type Operation struct {
+	r *rowSet
+}
+
+// StartOperation executes query without waiting for it to complete. Call it
+// through database/sql.Conn.Raw and close the operation before the callback
+// returns.
+func (c *hiveConnection) StartOperation(ctx context.Context, query string) (*Operation, error) {
+	resp, err := c.execute(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := newRows(c, resp.OperationHandle, ctx)
+	r.reportIDs()
+	return &Operation{r: r}, nil
+}
+
+// FetchLogs returns the operation log lines that hiveserver2 produced since
+// the previous call.
+func (o *Operation) FetchLogs() ([]string, error) {
+	return o.r.fetchLogs()
+}
+
+// Poll checks the status of the operation once and reports whether it
+// stopped. The error of a failed operation is an *OperationError.
+func (o *Operation) Poll() (bool, error) {
+	if err := o.r.poll(); err != nil {
+		return false, err
+	}
+	if !o.r.status.isStopped() {
+		return false, nil
+	}
+	return true, o.r.stoppedError()
+}
+
+// Wait polls the operation until it stops, backing off as ExecContext does.
+func (o *Operation) Wait() error {
+	return o.r.waitFinished()
+}
+
+// QueryID returns the Hive query ID of the operation, or an empty string if
+// hiveserver2 does not support GetQueryId.
+func (o *Operation) QueryID() string {
+	return o.r.queryID()
+}
+
+// OperationID returns the identifier of the hiveserver2 operation.
+func (o *Operation) OperationID() string {
+	return operationID(o.r.operation)
+}
+
+// Close closes the operation on hiveserver2, cancelling it if it still runs.
+func (o *Operation) Close() error {
+	return o.r.Close()
+}
+
+type queryIDFuncKey struct{}
+
+// WithQueryIDFunc returns a copy of ctx that makes statements run with it
+// call f as soon as hiveserver2 accepted them, before they complete, with
+// their Hive query ID and hiveserver2 operation ID. The query ID is empty if
+// hiveserver2 does not support GetQueryId.
+func WithQueryIDFunc(ctx context.Context, f func(queryID, operationID string)) context.Context {
+	return context.WithValue(ctx, queryIDFuncKey{}, f)
+}
+
+// reportIDs calls the function set with WithQueryIDFunc, if any.
+func (r *rowSet) reportIDs() {
+	if f, ok := r.ctx.Value(queryIDFuncKey{}).(func(string, string)); ok && f != nil {
+		f(r.queryID(), operationID(r.operation))
+	}
+}
+
+// queryID asks hiveserver2 for the Hive query ID of the operation once. It
+// returns an empty string if the server does not support GetQueryId, and
+// does not ask again after a failed lookup.
+func (r *rowSet) queryID() string {
+	if r.queryIDDone {
+		return r.qid
+	}
+	r.queryIDDone = true
+	req := hiveserver2.NewTGetQueryIdReq()
+	req.OperationHandle = r.operation
+	resp, err := r.thrift.GetQueryId(r.ctx, req)
+	if r.hc.checkErr(err) != nil {
+		return ""
+	}
+	r.qid = resp.GetQueryId()
+	return r.qid
+}
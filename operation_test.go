@@ -0,0 +1,51 @@
+package gohive
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func TestOperationID(t *testing.T) {
+	a := assert.New(t)
+	op := &hiveserver2.TOperationHandle{OperationId: &hiveserver2.THandleIdentifier{
+		GUID: []byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0,
+			0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+	}}
+	a.Equal("12345678-9abc-def0-0123-456789abcdef", operationID(op))
+	a.Equal("", operationID(nil))
+
+	err := &OperationError{QueryID: "hive_1", OperationID: operationID(op), Err: errors.New("boom")}
+	a.Equal("query hive_1 (operation 12345678-9abc-def0-0123-456789abcdef): boom", err.Error())
+	a.Equal("boom", errors.Unwrap(err).Error())
+}
+
+func TestQueryIDLookupFailsOnce(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	cfg, err := ParseDSN(srv.DSN())
+	a.NoError(err)
+	conn, err := NewConnector(cfg).Connect(context.Background())
+	a.NoError(err)
+	defer conn.Close()
+	hc := conn.(*hiveConnection)
+
+	// hiveserver2 rejects GetQueryId for an operation it does not know.
+	op := &hiveserver2.TOperationHandle{OperationId: &hiveserver2.THandleIdentifier{
+		GUID: make([]byte, 16), Secret: make([]byte, 16),
+	}}
+	r := newRows(hc, op, context.Background())
+	for i := 0; i < 2; i++ {
+		var opErr *OperationError
+		a.True(errors.As(r.operationError(errors.New("boom")), &opErr))
+		a.Equal("", opErr.QueryID)
+		a.Equal(operationID(op), opErr.OperationID)
+	}
+	a.Equal(1, srv.Calls("GetQueryId"))
+	a.False(hc.bad)
+}
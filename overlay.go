@@ -0,0 +1,66 @@
+package gohive
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// ConfOverlay holds Hive configuration that applies to a single query only,
+// on top of the session configuration. It is sent as
+// TExecuteStatementReq.ConfOverlay and does not leak into pooled sessions.
+//
+// A ConfOverlay can be passed as a query argument:
+//
+//	db.QueryContext(ctx, "SELECT ...", gohive.ConfOverlay{"mapreduce.job.queuename": "etl"})
+//
+// or attached to the context with WithConfOverlay.
+type ConfOverlay map[string]string
+
+type confOverlayKey struct{}
+
+// WithConfOverlay returns a copy of ctx that applies conf to queries run with
+// it. Overlays from enclosing contexts are kept unless overridden by conf.
+func WithConfOverlay(ctx context.Context, conf ConfOverlay) context.Context {
+	merged := ConfOverlay{}
+	if parent, ok := ctx.Value(confOverlayKey{}).(ConfOverlay); ok {
+		for k, v := range parent {
+			merged[k] = v
+		}
+	}
+	for k, v := range conf {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, confOverlayKey{}, merged)
+}
+
+// confOverlay collects the overlay from ctx and query arguments, with
+// arguments taking precedence. It returns nil if there is no overlay.
+func confOverlay(ctx context.Context, args []driver.NamedValue) map[string]string {
+	var conf map[string]string
+	add := func(c ConfOverlay) {
+		if conf == nil {
+			conf = make(map[string]string)
+		}
+		for k, v := range c {
+			conf[k] = v
+		}
+	}
+	if c, ok := ctx.Value(confOverlayKey{}).(ConfOverlay); ok {
+		add(c)
+	}
+	for _, arg := range args {
+		if c, ok := arg.Value.(ConfOverlay); ok {
+			add(c)
+		}
+	}
+	return conf
+}
+
+// CheckNamedValue lets ConfOverlay through as a query argument and leaves
+// other values to the default conversion of database/sql.
+func (c *hiveConnection) CheckNamedValue(nv *driver.NamedValue) error {
+	if _, ok := nv.Value.(ConfOverlay); ok {
+		return nil
+	}
+	return driver.ErrSkip
+}
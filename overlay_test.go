@@ -0,0 +1,26 @@
+package gohive
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfOverlay(t *testing.T) {
+	a := assert.New(t)
+	a.Nil(confOverlay(context.Background(), nil))
+
+	ctx := WithConfOverlay(context.Background(), ConfOverlay{"a": "1", "b": "1"})
+	ctx = WithConfOverlay(ctx, ConfOverlay{"b": "2"})
+	args := []driver.NamedValue{
+		{Ordinal: 1, Value: int64(3)},
+		{Ordinal: 2, Value: ConfOverlay{"c": "3"}},
+	}
+	a.Equal(map[string]string{"a": "1", "b": "2", "c": "3"}, confOverlay(ctx, args))
+
+	c := &hiveConnection{}
+	a.NoError(c.CheckNamedValue(&args[1]))
+	a.Equal(driver.ErrSkip, c.CheckNamedValue(&args[0]))
+}
@@ -0,0 +1,37 @@
+package gohive
+
+import (
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+// Progress reports the progress of a running operation. hiveserver2 only
+// reports a percentage and a task table when the query runs on Tez with
+// hive.server2.in.place.progress enabled.
+type Progress struct {
+	// State is the operation state, e.g. RUNNING_STATE.
+	State string
+	// Percentage is between 0 and 1, or -1 if hiveserver2 reported none.
+	Percentage float64
+	// Header and Rows form the table of tasks that beeline prints.
+	Header []string
+	Rows   [][]string
+	Footer string
+}
+
+// SetProgressFunc makes queries subsequently run on this connection call f
+// each time they poll the operation status. Call it through
+// database/sql.Conn.Raw. A nil f disables it.
+func (c *hiveConnection) SetProgressFunc(f func(Progress)) {
+	c.options.ProgressFunc = f
+}
+
+func newProgress(resp *hiveserver2.TGetOperationStatusResp) Progress {
+	p := Progress{State: resp.GetOperationState().String(), Percentage: -1}
+	if u := resp.GetProgressUpdateResponse(); u != nil {
+		p.Percentage = u.ProgressedPercentage
+		p.Header = u.HeaderNames
+		p.Rows = u.Rows
+		p.Footer = u.FooterSummary
+	}
+	return p
+}
@@ -0,0 +1,43 @@
+package gohive
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func TestProgressFunc(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("INSERT INTO t SELECT * FROM s", &hivetest.Result{
+		Running:  2,
+		Progress: []float64{0.25, 0.75},
+	})
+	db, err := sql.Open("hive", srv.DSN()+"&pollInterval=1ms")
+	a.NoError(err)
+	defer db.Close()
+
+	conn, err := db.Conn(context.Background())
+	a.NoError(err)
+	defer conn.Close()
+	var progress []Progress
+	a.NoError(conn.Raw(func(c interface{}) error {
+		c.(*hiveConnection).SetProgressFunc(func(p Progress) { progress = append(progress, p) })
+		return nil
+	}))
+	_, err = conn.ExecContext(context.Background(), "INSERT INTO t SELECT * FROM s")
+	a.NoError(err)
+
+	running := hiveserver2.TOperationState_RUNNING_STATE.String()
+	finished := hiveserver2.TOperationState_FINISHED_STATE.String()
+	a.Equal([]Progress{
+		{State: running, Percentage: 0.25, Header: []string{}, Rows: [][]string{}},
+		{State: running, Percentage: 0.75, Header: []string{}, Rows: [][]string{}},
+		{State: finished, Percentage: 1, Header: []string{}, Rows: [][]string{}},
+	}, progress)
+}
@@ -0,0 +1,369 @@
+package gohive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+)
+
+// recordEntry is a call in a recording. Request and Response hold the
+// JSON encoding of the generated TCLIService<Method>Args and
+// TCLIService<Method>Result structs.
+type recordEntry struct {
+	Method    string          `json:"method"`
+	Request   json.RawMessage `json:"request"`
+	Response  json.RawMessage `json:"response,omitempty"`
+	Exception string          `json:"exception,omitempty"`
+}
+
+// serviceStructs returns the argument and result structs of the TCLIService
+// method name.
+func serviceStructs(name string) (args, result thrift.TStruct, err error) {
+	switch name {
+	case "OpenSession":
+		return &hiveserver2.TCLIServiceOpenSessionArgs{}, &hiveserver2.TCLIServiceOpenSessionResult{}, nil
+	case "CloseSession":
+		return &hiveserver2.TCLIServiceCloseSessionArgs{}, &hiveserver2.TCLIServiceCloseSessionResult{}, nil
+	case "GetInfo":
+		return &hiveserver2.TCLIServiceGetInfoArgs{}, &hiveserver2.TCLIServiceGetInfoResult{}, nil
+	case "ExecuteStatement":
+		return &hiveserver2.TCLIServiceExecuteStatementArgs{}, &hiveserver2.TCLIServiceExecuteStatementResult{}, nil
+	case "GetTypeInfo":
+		return &hiveserver2.TCLIServiceGetTypeInfoArgs{}, &hiveserver2.TCLIServiceGetTypeInfoResult{}, nil
+	case "GetCatalogs":
+		return &hiveserver2.TCLIServiceGetCatalogsArgs{}, &hiveserver2.TCLIServiceGetCatalogsResult{}, nil
+	case "GetSchemas":
+		return &hiveserver2.TCLIServiceGetSchemasArgs{}, &hiveserver2.TCLIServiceGetSchemasResult{}, nil
+	case "GetTables":
+		return &hiveserver2.TCLIServiceGetTablesArgs{}, &hiveserver2.TCLIServiceGetTablesResult{}, nil
+	case "GetTableTypes":
+		return &hiveserver2.TCLIServiceGetTableTypesArgs{}, &hiveserver2.TCLIServiceGetTableTypesResult{}, nil
+	case "GetColumns":
+		return &hiveserver2.TCLIServiceGetColumnsArgs{}, &hiveserver2.TCLIServiceGetColumnsResult{}, nil
+	case "GetFunctions":
+		return &hiveserver2.TCLIServiceGetFunctionsArgs{}, &hiveserver2.TCLIServiceGetFunctionsResult{}, nil
+	case "GetPrimaryKeys":
+		return &hiveserver2.TCLIServiceGetPrimaryKeysArgs{}, &hiveserver2.TCLIServiceGetPrimaryKeysResult{}, nil
+	case "GetCrossReference":
+		return &hiveserver2.TCLIServiceGetCrossReferenceArgs{}, &hiveserver2.TCLIServiceGetCrossReferenceResult{}, nil
+	case "GetOperationStatus":
+		return &hiveserver2.TCLIServiceGetOperationStatusArgs{}, &hiveserver2.TCLIServiceGetOperationStatusResult{}, nil
+	case "CancelOperation":
+		return &hiveserver2.TCLIServiceCancelOperationArgs{}, &hiveserver2.TCLIServiceCancelOperationResult{}, nil
+	case "CloseOperation":
+		return &hiveserver2.TCLIServiceCloseOperationArgs{}, &hiveserver2.TCLIServiceCloseOperationResult{}, nil
+	case "GetResultSetMetadata":
+		return &hiveserver2.TCLIServiceGetResultSetMetadataArgs{}, &hiveserver2.TCLIServiceGetResultSetMetadataResult{}, nil
+	case "FetchResults":
+		return &hiveserver2.TCLIServiceFetchResultsArgs{}, &hiveserver2.TCLIServiceFetchResultsResult{}, nil
+	case "GetDelegationToken":
+		return &hiveserver2.TCLIServiceGetDelegationTokenArgs{}, &hiveserver2.TCLIServiceGetDelegationTokenResult{}, nil
+	case "CancelDelegationToken":
+		return &hiveserver2.TCLIServiceCancelDelegationTokenArgs{}, &hiveserver2.TCLIServiceCancelDelegationTokenResult{}, nil
+	case "RenewDelegationToken":
+		return &hiveserver2.TCLIServiceRenewDelegationTokenArgs{}, &hiveserver2.TCLIServiceRenewDelegationTokenResult{}, nil
+	case "GetQueryId":
+		return &hiveserver2.TCLIServiceGetQueryIdArgs{}, &hiveserver2.TCLIServiceGetQueryIdResult{}, nil
+	case "SetClientInfo":
+		return &hiveserver2.TCLIServiceSetClientInfoArgs{}, &hiveserver2.TCLIServiceSetClientInfoResult{}, nil
+	}
+	return nil, nil, fmt.Errorf("unknown TCLIService method %s", name)
+}
+
+// message is a decoded Thrift message.
+type message struct {
+	name      string
+	typeID    thrift.TMessageType
+	seqID     int32
+	body      thrift.TStruct
+	exception thrift.TApplicationException
+}
+
+// decodeMessage decodes a TBinaryProtocol message. isReply tells whether b
+// holds a call or its reply.
+func decodeMessage(ctx context.Context, b []byte, isReply bool) (*message, error) {
+	buf := thrift.NewTMemoryBufferLen(len(b))
+	buf.Write(b)
+	p := thrift.NewTBinaryProtocolConf(buf, nil)
+	name, typeID, seqID, err := p.ReadMessageBegin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m := &message{name: name, typeID: typeID, seqID: seqID}
+	if typeID == thrift.EXCEPTION {
+		m.exception = thrift.NewTApplicationException(thrift.UNKNOWN_APPLICATION_EXCEPTION, "")
+		if err := m.exception.Read(ctx, p); err != nil {
+			return nil, err
+		}
+		return m, p.ReadMessageEnd(ctx)
+	}
+	args, result, err := serviceStructs(name)
+	if err != nil {
+		return nil, err
+	}
+	m.body = args
+	if isReply {
+		m.body = result
+	}
+	if err := m.body.Read(ctx, p); err != nil {
+		return nil, err
+	}
+	return m, p.ReadMessageEnd(ctx)
+}
+
+// encodeMessage encodes a TBinaryProtocol message.
+func encodeMessage(ctx context.Context, m *message) ([]byte, error) {
+	buf := thrift.NewTMemoryBuffer()
+	p := thrift.NewTBinaryProtocolConf(buf, nil)
+	if err := p.WriteMessageBegin(ctx, m.name, m.typeID, m.seqID); err != nil {
+		return nil, err
+	}
+	body := m.body
+	if m.typeID == thrift.EXCEPTION {
+		body = m.exception
+	}
+	if err := body.Write(ctx, p); err != nil {
+		return nil, err
+	}
+	if err := p.WriteMessageEnd(ctx); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// recordingTransport passes calls through to a transport and records each
+// call with its reply to a JSON file, as an array of recordEntry.
+type recordingTransport struct {
+	thrift.TTransport
+	file *os.File
+	// request and reply accumulate the bytes of the current call.
+	request bytes.Buffer
+	reply   bytes.Buffer
+	pending *recordEntry
+	entries int
+}
+
+// recordings holds the files that open recordingTransports write to.
+var recordings = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: map[string]bool{}}
+
+// reserveRecording returns path, or if another connection is recording to
+// it, the first of path-2, path-3, ... (before the extension) that is free.
+func reserveRecording(path string) string {
+	recordings.Lock()
+	defer recordings.Unlock()
+	p := path
+	ext := filepath.Ext(path)
+	for n := 2; recordings.paths[p]; n++ {
+		p = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), n, ext)
+	}
+	recordings.paths[p] = true
+	return p
+}
+
+func releaseRecording(path string) {
+	recordings.Lock()
+	defer recordings.Unlock()
+	delete(recordings.paths, path)
+}
+
+func newRecordingTransport(transport thrift.TTransport, path string) (*recordingTransport, error) {
+	path = reserveRecording(path)
+	f, err := os.Create(path)
+	if err != nil {
+		releaseRecording(path)
+		return nil, err
+	}
+	if _, err := f.WriteString("["); err != nil {
+		f.Close()
+		releaseRecording(path)
+		return nil, err
+	}
+	return &recordingTransport{TTransport: transport, file: f}, nil
+}
+
+func (t *recordingTransport) Write(p []byte) (int, error) {
+	t.request.Write(p)
+	return t.TTransport.Write(p)
+}
+
+func (t *recordingTransport) Read(p []byte) (int, error) {
+	n, err := t.TTransport.Read(p)
+	t.reply.Write(p[:n])
+	return n, err
+}
+
+// Flush sends a call. Calls are synchronous, so the reply of the previous
+// call has been read completely by then and is recorded first.
+func (t *recordingTransport) Flush(ctx context.Context) error {
+	if err := t.recordReply(ctx); err != nil {
+		return err
+	}
+	m, err := decodeMessage(ctx, t.request.Bytes(), false)
+	if err != nil {
+		return fmt.Errorf("record %s: %v", t.file.Name(), err)
+	}
+	t.request.Reset()
+	redact(m.body)
+	t.pending = &recordEntry{Method: m.name}
+	if t.pending.Request, err = json.Marshal(m.body); err != nil {
+		return err
+	}
+	return t.TTransport.Flush(ctx)
+}
+
+// redactedValue replaces credentials in recordings.
+const redactedValue = "REDACTED"
+
+// redact removes the password of OpenSession and configuration values whose
+// keys mention a password from a decoded call before it is recorded.
+func redact(body interface{}) {
+	args, ok := body.(*hiveserver2.TCLIServiceOpenSessionArgs)
+	if !ok || args.Req == nil {
+		return
+	}
+	if args.Req.Password != nil {
+		p := redactedValue
+		args.Req.Password = &p
+	}
+	for k := range args.Req.Configuration {
+		if strings.Contains(strings.ToLower(k), "password") {
+			args.Req.Configuration[k] = redactedValue
+		}
+	}
+}
+
+func (t *recordingTransport) recordReply(ctx context.Context) error {
+	if t.pending == nil {
+		return nil
+	}
+	e := t.pending
+	t.pending = nil
+	m, err := decodeMessage(ctx, t.reply.Bytes(), true)
+	t.reply.Reset()
+	if err != nil {
+		return fmt.Errorf("record %s: %v", t.file.Name(), err)
+	}
+	if m.typeID == thrift.EXCEPTION {
+		e.Exception = m.exception.Error()
+	} else if e.Response, err = json.Marshal(m.body); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(e, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if t.entries == 0 {
+		sep = "\n  "
+	}
+	t.entries++
+	_, err = t.file.WriteString(sep + string(b))
+	return err
+}
+
+// Close records the reply of the last call and completes the file.
+func (t *recordingTransport) Close() error {
+	err := t.recordReply(context.Background())
+	if _, e := t.file.WriteString("\n]\n"); err == nil {
+		err = e
+	}
+	if e := t.file.Close(); err == nil {
+		err = e
+	}
+	releaseRecording(t.file.Name())
+	if e := t.TTransport.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// replayTransport answers calls with the replies of a recording, in order.
+// Calls are only matched by method name, and replies are re-encoded with
+// the sequence ID of the call.
+type replayTransport struct {
+	path    string
+	entries []recordEntry
+	next    int
+	request bytes.Buffer
+	reply   bytes.Buffer
+}
+
+func newReplayTransport(path string) (*replayTransport, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &replayTransport{path: path}
+	if err := json.Unmarshal(b, &t.entries); err != nil {
+		return nil, fmt.Errorf("replay %s: %v", path, err)
+	}
+	return t, nil
+}
+
+func (t *replayTransport) Open() error {
+	return nil
+}
+
+func (t *replayTransport) IsOpen() bool {
+	return true
+}
+
+func (t *replayTransport) Close() error {
+	return nil
+}
+
+func (t *replayTransport) Write(p []byte) (int, error) {
+	return t.request.Write(p)
+}
+
+func (t *replayTransport) Read(p []byte) (int, error) {
+	return t.reply.Read(p)
+}
+
+func (t *replayTransport) RemainingBytes() uint64 {
+	return uint64(t.reply.Len())
+}
+
+func (t *replayTransport) Flush(ctx context.Context) error {
+	call, err := decodeMessage(ctx, t.request.Bytes(), false)
+	t.request.Reset()
+	if err != nil {
+		return fmt.Errorf("replay %s: %v", t.path, err)
+	}
+	if t.next >= len(t.entries) {
+		return fmt.Errorf("replay %s: unexpected call %s after the end of the recording", t.path, call.name)
+	}
+	e := t.entries[t.next]
+	t.next++
+	if e.Method != call.name {
+		return fmt.Errorf("replay %s: call %d is %s, expected %s", t.path, t.next, call.name, e.Method)
+	}
+
+	reply := &message{name: call.name, typeID: thrift.REPLY, seqID: call.seqID}
+	if e.Exception != "" {
+		reply.typeID = thrift.EXCEPTION
+		reply.exception = thrift.NewTApplicationException(thrift.INTERNAL_ERROR, e.Exception)
+	} else {
+		_, reply.body, _ = serviceStructs(call.name)
+		if err := json.Unmarshal(e.Response, reply.body); err != nil {
+			return fmt.Errorf("replay %s: %v", t.path, err)
+		}
+	}
+	b, err := encodeMessage(ctx, reply)
+	if err != nil {
+		return err
+	}
+	t.reply.Write(b)
+	return nil
+}
@@ -0,0 +1,143 @@
+package gohive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func queryAll(db *sql.DB, query string) ([][]interface{}, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret [][]interface{}
+	for rows.Next() {
+		var name string
+		var age sql.NullInt64
+		if err := rows.Scan(&name, &age); err != nil {
+			return nil, err
+		}
+		ret = append(ret, []interface{}{name, age})
+	}
+	return ret, rows.Err()
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("PLAIN")
+	srv.Handle("SELECT name, age FROM people", &hivetest.Result{
+		Columns: []hivetest.Column{
+			{Name: "name", Type: hiveserver2.TTypeId_STRING_TYPE},
+			{Name: "age", Type: hiveserver2.TTypeId_BIGINT_TYPE},
+		},
+		Rows:    [][]interface{}{{"alice", 31}, {"bob", nil}},
+		Running: 2,
+	})
+	path := filepath.Join(t.TempDir(), "people.json")
+
+	db, err := sql.Open("hive", "user:secret@"+srv.DSN()+"&pollInterval=1ms&record="+path)
+	a.NoError(err)
+	recorded, err := queryAll(db, "SELECT name, age FROM people")
+	a.NoError(err)
+	a.NoError(db.Close())
+	srv.Close()
+
+	b, err := os.ReadFile(path)
+	a.NoError(err)
+	var entries []recordEntry
+	a.NoError(json.Unmarshal(b, &entries))
+	var methods []string
+	for _, e := range entries {
+		methods = append(methods, e.Method)
+	}
+	a.Equal([]string{"OpenSession", "ExecuteStatement", "GetOperationStatus", "GetOperationStatus",
+		"GetOperationStatus", "GetResultSetMetadata", "FetchResults", "FetchResults", "CloseOperation", "CloseSession"}, methods)
+	a.Contains(string(b), `"statement": "SELECT name, age FROM people"`)
+
+	// The server is gone, replay serves the same results.
+	db, err = sql.Open("hive", "user:secret@"+srv.DSN()+"&pollInterval=1ms&replay="+path)
+	a.NoError(err)
+	defer db.Close()
+	replayed, err := queryAll(db, "SELECT name, age FROM people")
+	a.NoError(err)
+	a.Equal(recorded, replayed)
+	a.Equal([][]interface{}{
+		{"alice", sql.NullInt64{Int64: 31, Valid: true}},
+		{"bob", sql.NullInt64{}},
+	}, replayed)
+}
+
+func TestRecordRedactsPasswords(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("PLAIN")
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	db, err := sql.Open("hive", "user:pa55word@"+srv.DSN()+"&record="+path+
+		"&hiveconf.javax.jdo.option.ConnectionPassword=hunter2&hiveconf.mapreduce.job.queuename=etl")
+	a.NoError(err)
+	a.NoError(db.Ping())
+	a.NoError(db.Close())
+
+	b, err := os.ReadFile(path)
+	a.NoError(err)
+	a.NotContains(string(b), "pa55word")
+	a.NotContains(string(b), "hunter2")
+	a.Contains(string(b), `"password": "REDACTED"`)
+	a.Contains(string(b), `"set:hiveconf:javax.jdo.option.ConnectionPassword": "REDACTED"`)
+	a.Contains(string(b), `"set:hiveconf:mapreduce.job.queuename": "etl"`)
+}
+
+func TestRecordConcurrentConnections(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.json")
+
+	db, err := sql.Open("hive", srv.DSN()+"&record="+path)
+	a.NoError(err)
+	ctx := context.Background()
+	c1, err := db.Conn(ctx)
+	a.NoError(err)
+	c2, err := db.Conn(ctx)
+	a.NoError(err)
+	a.NoError(c1.Close())
+	a.NoError(c2.Close())
+	a.NoError(db.Close())
+
+	for _, name := range []string{"session.json", "session-2.json"} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		a.NoError(err)
+		var entries []recordEntry
+		a.NoError(json.Unmarshal(b, &entries), name)
+		a.Equal("OpenSession", entries[0].Method)
+		a.Equal("CloseSession", entries[len(entries)-1].Method)
+	}
+	// Both files are released, so the next connection uses the plain name.
+	rt, err := newRecordingTransport(nil, path)
+	a.NoError(err)
+	a.Equal(path, rt.file.Name())
+	rt.file.Close()
+	releaseRecording(path)
+}
+
+func TestReplayUnexpectedCall(t *testing.T) {
+	a := assert.New(t)
+	path := filepath.Join(t.TempDir(), "empty.json")
+	a.NoError(os.WriteFile(path, []byte(`[{"method": "CloseSession", "request": {}, "response": {}}]`), 0644))
+
+	db, err := sql.Open("hive", "localhost:10000?replay="+path)
+	a.NoError(err)
+	defer db.Close()
+	a.ErrorContains(db.Ping(), "call 1 is OpenSession, expected CloseSession")
+}
@@ -2,8 +2,6 @@ package gohive
 
 import (
 	"database/sql/driver"
-
-	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
 )
 
 type hiveResult struct {
@@ -19,10 +17,10 @@ func (r *hiveResult) RowsAffected() (int64, error) {
 	return r.affected, nil
 }
 
-func newHiveResult(op *hiveserver2.TOperationHandle) driver.Result {
+func newHiveResult(r *rowSet) driver.Result {
 	var na int64 = -1
-	if op.ModifiedRowCount != nil {
-		na = int64(*op.ModifiedRowCount)
+	if r.operation.ModifiedRowCount != nil {
+		na = int64(*r.operation.ModifiedRowCount)
 	}
 	return &hiveResult{insertId: -1, affected: na}
 }
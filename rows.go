@@ -12,8 +12,19 @@ import (
 	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
 )
 
+// operationCallTimeout bounds the CancelOperation and CloseOperation calls
+// made after the context of a query is done.
+const operationCallTimeout = 5 * time.Second
+
+// TFetchResultsReq.FetchType values understood by hiveserver2.
+const (
+	fetchTypeQueryOutput int16 = 0
+	fetchTypeLog         int16 = 1
+)
+
 // rowSet implements the interface database/sql/driver.Rows.
 type rowSet struct {
+	hc        *hiveConnection
 	thrift    *hiveserver2.TCLIServiceClient
 	operation *hiveserver2.TOperationHandle
 	options   hiveOptions
@@ -27,19 +38,28 @@ type rowSet struct {
 	// resultSet is column-oriented storage format
 	resultSet [][]interface{}
 	status    *hiveStatus
+	// qid caches the Hive query ID once queryIDDone is set.
+	qid         string
+	queryIDDone bool
+	closed      bool
+	// noLogs is set once fetching or writing the operation logs failed.
+	noLogs bool
 
 	ctx context.Context
 }
 
 type hiveStatus struct {
-	state *hiveserver2.TOperationState
+	state        *hiveserver2.TOperationState
+	errorMessage string
 }
 
-func (r *rowSet) Next(dest []driver.Value) error {
+// waitResult waits for the operation to finish successfully and reads the
+// schema of its result.
+func (r *rowSet) waitResult() error {
 	if r.status == nil || !r.status.isStopped() {
 		err := r.wait()
 		if err != nil {
-			return nil
+			return err
 		}
 	}
 	if r.status == nil {
@@ -48,6 +68,13 @@ func (r *rowSet) Next(dest []driver.Value) error {
 	if !r.status.isFinished() {
 		return fmt.Errorf("job failed.")
 	}
+	return nil
+}
+
+func (r *rowSet) Next(dest []driver.Value) error {
+	if err := r.waitResult(); err != nil {
+		return err
+	}
 	// First execution or reach the end of the current result set.
 	if r.resultSet == nil || r.offset >= len(r.resultSet[0]) {
 		r.offset = 0
@@ -93,10 +120,42 @@ func (r *rowSet) Columns() []string {
 	return r.columnStrs
 }
 
-func (r *rowSet) Close() (err error) {
+// Close closes the operation, releasing its resources on hiveserver2.
+func (r *rowSet) Close() error {
+	if r.closed || !r.hc.IsValid() {
+		return nil
+	}
+	r.closed = true
+	ctx, cancel := context.WithTimeout(context.Background(), operationCallTimeout)
+	defer cancel()
+	req := hiveserver2.NewTCloseOperationReq()
+	req.OperationHandle = r.operation
+	resp, err := r.thrift.CloseOperation(ctx, req)
+	if err != nil {
+		return r.hc.checkErr(err)
+	}
+	if !isSuccessStatus(resp.Status) {
+		return fmt.Errorf("CloseOperation failed: %s", statusMessage(resp.Status))
+	}
 	return nil
 }
 
+// cancel asks hiveserver2 to stop the operation, which otherwise keeps
+// running on the cluster after the client gave up. r.ctx is done by then,
+// so the call gets a context of its own.
+func (r *rowSet) cancel() {
+	if !r.hc.IsValid() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), operationCallTimeout)
+	defer cancel()
+	req := hiveserver2.NewTCancelOperationReq()
+	req.OperationHandle = r.operation
+	if _, err := r.thrift.CancelOperation(ctx, req); err != nil {
+		r.hc.checkErr(err)
+	}
+}
+
 var (
 	scanTypeVarchar = reflect.TypeOf("varchar")
 	scanTypeBool    = reflect.TypeOf(true)
@@ -147,99 +206,320 @@ func (r *rowSet) ColumnTypeDatabaseTypeName(i int) string {
 func (r *rowSet) poll() error {
 	req := hiveserver2.NewTGetOperationStatusReq()
 	req.OperationHandle = r.operation
+	if r.options.ProgressFunc != nil {
+		getProgress := true
+		req.GetProgressUpdate = &getProgress
+	}
 
 	resp, err := r.thrift.GetOperationStatus(r.ctx, req)
 	if err != nil {
-		return fmt.Errorf("Error getting status: %+v, %v", resp, err)
+		return fmt.Errorf("Error getting status: %+v, %w", resp, r.hc.checkErr(err))
 	}
 	if !isSuccessStatus(resp.Status) {
-		return fmt.Errorf("GetStatus call failed: %s", resp.Status.String())
+		r.hc.checkStatus(resp.Status)
+		return fmt.Errorf("GetStatus call failed: %s", statusMessage(resp.Status))
 	}
 	if resp.OperationState == nil {
 		return errors.New("No error from GetStatus, but nil status!")
 	}
-	r.status = &hiveStatus{resp.OperationState}
+	r.status = &hiveStatus{resp.OperationState, resp.GetErrorMessage()}
+	if r.options.ProgressFunc != nil {
+		r.options.ProgressFunc(newProgress(resp))
+	}
 	return nil
 }
 
-func (r *rowSet) wait() error {
+func (r *rowSet) operationError(err error) error {
+	return &OperationError{QueryID: r.queryID(), OperationID: operationID(r.operation), Err: err}
+}
+
+// fetchLogs returns the operation log lines that hiveserver2 produced since
+// the previous call. It can be called while the query is running and after
+// it finishes.
+func (r *rowSet) fetchLogs() ([]string, error) {
+	fetchReq := hiveserver2.NewTFetchResultsReq()
+	fetchReq.OperationHandle = r.operation
+	fetchReq.Orientation = hiveserver2.TFetchOrientation_FETCH_NEXT
+	fetchReq.MaxRows = r.options.BatchSize
+	fetchReq.FetchType = fetchTypeLog
+
+	resp, err := r.thrift.FetchResults(r.ctx, fetchReq)
+	if err != nil {
+		return nil, r.hc.checkErr(err)
+	}
+	if !isSuccessStatus(resp.Status) {
+		return nil, fmt.Errorf("FetchResults of logs failed: %s", statusMessage(resp.Status))
+	}
+	rs := resp.GetResults()
+	if rs == nil {
+		return nil, nil
+	}
+	if len(rs.Columns) > 0 {
+		return rs.Columns[0].GetStringVal().GetValues(), nil
+	}
+	logs := make([]string, 0, len(rs.Rows))
+	for _, row := range rs.Rows {
+		if len(row.ColVals) > 0 {
+			logs = append(logs, row.ColVals[0].GetStringVal().GetValue())
+		}
+	}
+	return logs, nil
+}
+
+// writeLogs tees the newly produced operation logs to options.LogWriter.
+// Logs are best-effort: after the first failure, e.g. from a server that
+// keeps no operation logs, they are no longer fetched for the operation.
+func (r *rowSet) writeLogs() {
+	if r.options.LogWriter == nil || r.noLogs {
+		return
+	}
+	for {
+		logs, err := r.fetchLogs()
+		if err != nil || len(logs) == 0 {
+			r.noLogs = err != nil
+			return
+		}
+		for _, l := range logs {
+			if _, err := io.WriteString(r.options.LogWriter, l+"\n"); err != nil {
+				r.noLogs = true
+				return
+			}
+		}
+	}
+}
+
+// waitFinished polls the operation status until the operation stops.
+func (r *rowSet) waitFinished() error {
+	interval := r.options.PollInterval
 	for {
 		err := r.poll()
 		if err != nil {
 			return err
 		}
+		r.writeLogs()
 		if r.status.isStopped() {
-			if r.status.isFinished() {
-				metadataReq := hiveserver2.NewTGetResultSetMetadataReq()
-				metadataReq.OperationHandle = r.operation
-
-				metadataResp, err := r.thrift.GetResultSetMetadata(r.ctx, metadataReq)
-				if err != nil {
-					return err
-				}
-				if !isSuccessStatus(metadataResp.Status) {
-					return fmt.Errorf("GetResultSetMetadata failed: %s",
-						metadataResp.Status.String())
-				}
-				r.columns = metadataResp.Schema.Columns
-				return nil
-			} else {
-				return fmt.Errorf("Query failed execution: %s", r.status.state.String())
-			}
+			return r.stoppedError()
+		}
+		select {
+		case <-r.ctx.Done():
+			r.cancel()
+			return r.ctx.Err()
+		case <-time.After(interval):
+		}
+		// With long polling hiveserver2 already waits in GetOperationStatus,
+		// so keep the short interval that only guards against busy loops.
+		if r.options.LongPolling {
+			continue
+		}
+		if interval *= 2; interval > r.options.MaxPollInterval {
+			interval = r.options.MaxPollInterval
 		}
-		time.Sleep(time.Duration(r.options.PollIntervalSeconds) * time.Second)
 	}
 }
 
-func (r *rowSet) batchFetch() error {
+// stoppedError returns the error of a stopped operation, or nil if it
+// finished successfully.
+func (r *rowSet) stoppedError() error {
+	if r.status.isFinished() {
+		return nil
+	}
+	if r.status.isTimedOut() {
+		return r.operationError(ErrQueryTimeout)
+	}
+	return r.operationError(fmt.Errorf("Query failed execution: %s %s",
+		r.status.state.String(), r.status.errorMessage))
+}
+
+func (r *rowSet) wait() error {
+	if err := r.waitFinished(); err != nil {
+		return err
+	}
+	metadataReq := hiveserver2.NewTGetResultSetMetadataReq()
+	metadataReq.OperationHandle = r.operation
+
+	metadataResp, err := r.thrift.GetResultSetMetadata(r.ctx, metadataReq)
+	if err != nil {
+		return r.hc.checkErr(err)
+	}
+	if !isSuccessStatus(metadataResp.Status) {
+		return fmt.Errorf("GetResultSetMetadata failed: %s",
+			statusMessage(metadataResp.Status))
+	}
+	r.columns = metadataResp.Schema.Columns
+	return nil
+}
+
+// Schema returns the schema of the result set, waiting for the operation to
+// finish if necessary.
+func (r *rowSet) Schema() (*hiveserver2.TTableSchema, error) {
+	if err := r.waitResult(); err != nil {
+		return nil, err
+	}
+	return &hiveserver2.TTableSchema{Columns: r.columns}, nil
+}
+
+// FetchColumns fetches the next batch of rows in the column-oriented form
+// hiveserver2 sends them, without boxing values. It returns io.EOF after the
+// last batch, and must not be mixed with Next on the same rows.
+func (r *rowSet) FetchColumns() ([]*hiveserver2.TColumn, error) {
+	if err := r.waitResult(); err != nil {
+		return nil, err
+	}
+	if err := r.fetchRowSet(); err != nil {
+		return nil, err
+	}
+	cols := r.rowSet.Columns
+	if len(cols) == 0 {
+		if len(r.rowSet.Rows) == 0 {
+			return nil, io.EOF
+		}
+		return nil, errors.New("FetchColumns does not support row-oriented results")
+	}
+	if _, _, length := convertColumn(cols[0]); length == 0 {
+		return nil, io.EOF
+	}
+	return cols, nil
+}
+
+// fetchRowSet fetches the next batch of rows into r.rowSet, decoding binary
+// columns.
+func (r *rowSet) fetchRowSet() error {
 	fetchReq := hiveserver2.NewTFetchResultsReq()
 	fetchReq.OperationHandle = r.operation
 	fetchReq.Orientation = hiveserver2.TFetchOrientation_FETCH_NEXT
 	fetchReq.MaxRows = r.options.BatchSize
+	fetchReq.FetchType = fetchTypeQueryOutput
 
 	resp, err := r.thrift.FetchResults(r.ctx, fetchReq)
 	if err != nil {
-		return err
+		return r.hc.checkErr(err)
 	}
 	if !isSuccessStatus(resp.Status) {
-		return fmt.Errorf("FetchResults failed: %s\n", resp.Status.String())
+		return fmt.Errorf("FetchResults failed: %s\n", statusMessage(resp.Status))
 	}
 	r.rowSet = resp.GetResults()
 
+	// Results serialized in tasks or compressed by a result set compressor
+	// plugin arrive in BinaryColumns.
+	if r.rowSet.IsSetBinaryColumns() {
+		cols, err := decodeBinaryColumns(r.ctx, r.rowSet.BinaryColumns,
+			int(r.rowSet.GetColumnCount()), r.options.Decompressor)
+		if err != nil {
+			return err
+		}
+		r.rowSet.Columns = cols
+	}
+	return nil
+}
+
+func (r *rowSet) batchFetch() error {
+	if err := r.fetchRowSet(); err != nil {
+		return err
+	}
+
+	// Older Hive releases and some HiveServer2-compatible engines return
+	// row-oriented results, leaving Columns empty.
+	if len(r.rowSet.Columns) == 0 {
+		r.resultSet = convertRows(r.rowSet.Rows, len(r.columns))
+		return nil
+	}
+
 	rs := r.rowSet.Columns
 	colLen := len(rs)
 	r.resultSet = make([][]interface{}, colLen)
 
 	for i := 0; i < colLen; i++ {
-		v, length := convertColumn(rs[i])
+		v, nulls, length := convertColumn(rs[i])
 		c := make([]interface{}, length)
 		for j := 0; j < length; j++ {
-			c[j] = reflect.ValueOf(v).Index(j).Interface()
+			if !isNull(nulls, j) {
+				c[j] = reflect.ValueOf(v).Index(j).Interface()
+			}
 		}
 		r.resultSet[i] = c
 	}
 	return nil
 }
 
-func convertColumn(col *hiveserver2.TColumn) (colValues interface{}, length int) {
+// convertColumn returns the values of col with the bitmap of its NULL
+// values, in which bit j of byte j/8 is set when row j is NULL.
+func convertColumn(col *hiveserver2.TColumn) (colValues interface{}, nulls []byte, length int) {
 	switch {
 	case col.IsSetStringVal():
-		return col.GetStringVal().GetValues(), len(col.GetStringVal().GetValues())
+		return col.GetStringVal().GetValues(), col.GetStringVal().GetNulls(), len(col.GetStringVal().GetValues())
 	case col.IsSetBoolVal():
-		return col.GetBoolVal().GetValues(), len(col.GetBoolVal().GetValues())
+		return col.GetBoolVal().GetValues(), col.GetBoolVal().GetNulls(), len(col.GetBoolVal().GetValues())
 	case col.IsSetByteVal():
-		return col.GetByteVal().GetValues(), len(col.GetByteVal().GetValues())
+		return col.GetByteVal().GetValues(), col.GetByteVal().GetNulls(), len(col.GetByteVal().GetValues())
 	case col.IsSetI16Val():
-		return col.GetI16Val().GetValues(), len(col.GetI16Val().GetValues())
+		return col.GetI16Val().GetValues(), col.GetI16Val().GetNulls(), len(col.GetI16Val().GetValues())
 	case col.IsSetI32Val():
-		return col.GetI32Val().GetValues(), len(col.GetI32Val().GetValues())
+		return col.GetI32Val().GetValues(), col.GetI32Val().GetNulls(), len(col.GetI32Val().GetValues())
 	case col.IsSetI64Val():
-		return col.GetI64Val().GetValues(), len(col.GetI64Val().GetValues())
+		return col.GetI64Val().GetValues(), col.GetI64Val().GetNulls(), len(col.GetI64Val().GetValues())
 	case col.IsSetDoubleVal():
-		return col.GetDoubleVal().GetValues(), len(col.GetDoubleVal().GetValues())
+		return col.GetDoubleVal().GetValues(), col.GetDoubleVal().GetNulls(), len(col.GetDoubleVal().GetValues())
+	case col.IsSetBinaryVal():
+		return col.GetBinaryVal().GetValues(), col.GetBinaryVal().GetNulls(), len(col.GetBinaryVal().GetValues())
 	default:
-		return nil, 0
+		return nil, nil, 0
+	}
+}
+
+// isNull reports whether row j is set in a TColumn nulls bitmap.
+func isNull(nulls []byte, j int) bool {
+	return j/8 < len(nulls) && nulls[j/8]&(1<<(uint(j)%8)) != 0
+}
+
+// convertRows transposes row-oriented TRow values into the column-oriented
+// layout of resultSet. NULL values are stored as nil.
+func convertRows(rows []*hiveserver2.TRow, colLen int) [][]interface{} {
+	resultSet := make([][]interface{}, colLen)
+	for i := range resultSet {
+		resultSet[i] = make([]interface{}, len(rows))
+	}
+	for j, row := range rows {
+		for i, v := range row.GetColVals() {
+			if i < colLen {
+				resultSet[i][j] = convertColumnValue(v)
+			}
+		}
 	}
+	return resultSet
+}
+
+func convertColumnValue(v *hiveserver2.TColumnValue) interface{} {
+	switch {
+	case v.IsSetStringVal():
+		if v.StringVal.IsSetValue() {
+			return v.StringVal.GetValue()
+		}
+	case v.IsSetBoolVal():
+		if v.BoolVal.IsSetValue() {
+			return v.BoolVal.GetValue()
+		}
+	case v.IsSetByteVal():
+		if v.ByteVal.IsSetValue() {
+			return v.ByteVal.GetValue()
+		}
+	case v.IsSetI16Val():
+		if v.I16Val.IsSetValue() {
+			return v.I16Val.GetValue()
+		}
+	case v.IsSetI32Val():
+		if v.I32Val.IsSetValue() {
+			return v.I32Val.GetValue()
+		}
+	case v.IsSetI64Val():
+		if v.I64Val.IsSetValue() {
+			return v.I64Val.GetValue()
+		}
+	case v.IsSetDoubleVal():
+		if v.DoubleVal.IsSetValue() {
+			return v.DoubleVal.GetValue()
+		}
+	}
+	return nil
 }
 
 func (s hiveStatus) isStopped() bool {
@@ -250,17 +530,25 @@ func (s hiveStatus) isStopped() bool {
 	case hiveserver2.TOperationState_FINISHED_STATE,
 		hiveserver2.TOperationState_CANCELED_STATE,
 		hiveserver2.TOperationState_CLOSED_STATE,
-		hiveserver2.TOperationState_ERROR_STATE:
+		hiveserver2.TOperationState_ERROR_STATE,
+		hiveserver2.TOperationState_TIMEDOUT_STATE:
 		return true
 	}
 	return false
 }
 
+func (s hiveStatus) isTimedOut() bool {
+	return s.state != nil && *s.state == hiveserver2.TOperationState_TIMEDOUT_STATE
+}
+
 func (s hiveStatus) isFinished() bool {
 	return s.state != nil && *s.state == hiveserver2.TOperationState_FINISHED_STATE
 }
 
-func newRows(thrift *hiveserver2.TCLIServiceClient, operation *hiveserver2.TOperationHandle, options hiveOptions, ctx context.Context) driver.Rows {
-	return &rowSet{thrift, operation, options, nil, nil,
-		0, nil, nil, nil, ctx}
+func newRows(hc *hiveConnection, operation *hiveserver2.TOperationHandle, ctx context.Context) *rowSet {
+	options := hc.options
+	if w, ok := ctx.Value(logWriterKey{}).(io.Writer); ok {
+		options.LogWriter = w
+	}
+	return &rowSet{hc: hc, thrift: hc.thrift, operation: operation, options: options, ctx: ctx}
 }
@@ -0,0 +1,166 @@
+package gohive
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func TestConvertRows(t *testing.T) {
+	a := assert.New(t)
+	str := "Female"
+	i32 := int32(42)
+	rows := []*hiveserver2.TRow{
+		{ColVals: []*hiveserver2.TColumnValue{
+			{StringVal: &hiveserver2.TStringValue{Value: &str}},
+			{I32Val: &hiveserver2.TI32Value{Value: &i32}},
+		}},
+		{ColVals: []*hiveserver2.TColumnValue{
+			{StringVal: &hiveserver2.TStringValue{}},
+			{I32Val: &hiveserver2.TI32Value{}},
+		}},
+	}
+	rs := convertRows(rows, 2)
+	a.Equal([][]interface{}{{"Female", nil}, {int32(42), nil}}, rs)
+
+	rs = convertRows(nil, 2)
+	a.Equal(2, len(rs))
+	a.Equal(0, len(rs[0]))
+}
+
+func TestConvertColumnNulls(t *testing.T) {
+	a := assert.New(t)
+	col := &hiveserver2.TColumn{I32Val: &hiveserver2.TI32Column{
+		Values: []int32{1, 0, 3, 4, 5, 6, 7, 8, 0},
+		Nulls:  []byte{0x02, 0x01},
+	}}
+	v, nulls, n := convertColumn(col)
+	a.Equal(9, n)
+	a.Equal(col.I32Val.Values, v)
+	var null []int
+	for j := 0; j < n; j++ {
+		if isNull(nulls, j) {
+			null = append(null, j)
+		}
+	}
+	a.Equal([]int{1, 8}, null)
+	// A short bitmap leaves the remaining rows non-NULL.
+	a.False(isNull([]byte{0xff}, 8))
+
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("SELECT n, s FROM t", &hivetest.Result{
+		Columns: []hivetest.Column{
+			{Name: "n", Type: hiveserver2.TTypeId_INT_TYPE},
+			{Name: "s", Type: hiveserver2.TTypeId_STRING_TYPE},
+		},
+		Rows: [][]interface{}{{1, nil}, {nil, "b"}},
+	})
+	db, err := sql.Open("hive", srv.DSN())
+	a.NoError(err)
+	defer db.Close()
+	rows, err := db.Query("SELECT n, s FROM t")
+	a.NoError(err)
+	defer rows.Close()
+	var got [][]interface{}
+	for rows.Next() {
+		var n, s interface{}
+		a.NoError(rows.Scan(&n, &s))
+		got = append(got, []interface{}{n, s})
+	}
+	a.Equal([][]interface{}{{int32(1), nil}, {nil, "b"}}, got)
+}
+
+func TestCancelOperationOnContextDone(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("INSERT INTO t SELECT * FROM s", &hivetest.Result{Running: 1 << 30})
+	db, err := sql.Open("hive", srv.DSN()+"&pollInterval=1ms")
+	a.NoError(err)
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "INSERT INTO t SELECT * FROM s")
+	a.ErrorIs(err, context.DeadlineExceeded)
+	a.Equal(1, srv.Calls("CancelOperation"))
+	a.Equal(1, srv.Calls("CloseOperation"))
+}
+
+func TestCloseOperation(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("SELECT 1", &hivetest.Result{
+		Columns: []hivetest.Column{{Name: "_c0", Type: hiveserver2.TTypeId_INT_TYPE}},
+		Rows:    [][]interface{}{{1}},
+	})
+	db, err := sql.Open("hive", srv.DSN()+"&pollInterval=1ms")
+	a.NoError(err)
+	defer db.Close()
+
+	rows, err := db.Query("SELECT 1")
+	a.NoError(err)
+	a.True(rows.Next())
+	a.Equal(0, srv.Calls("CloseOperation"))
+	a.NoError(rows.Close())
+	a.Equal(1, srv.Calls("CloseOperation"))
+	a.Equal(0, srv.Calls("CancelOperation"))
+}
+
+// pollGaps runs a statement that stays RUNNING for running status calls with
+// the DSN parameters params, and returns the time between the calls.
+func pollGaps(t *testing.T, running int, params string) []time.Duration {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.Handle("INSERT INTO t SELECT * FROM s", &hivetest.Result{Running: running})
+	db, err := sql.Open("hive", srv.DSN()+params)
+	a.NoError(err)
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	a.NoError(err)
+	defer conn.Close()
+
+	var polls []time.Time
+	a.NoError(conn.Raw(func(c interface{}) error {
+		c.(*hiveConnection).SetProgressFunc(func(Progress) { polls = append(polls, time.Now()) })
+		return nil
+	}))
+	_, err = conn.ExecContext(context.Background(), "INSERT INTO t SELECT * FROM s")
+	a.NoError(err)
+	a.Equal(running+1, srv.Calls("GetOperationStatus"))
+	a.Len(polls, running+1)
+	var gaps []time.Duration
+	for i := 1; i < len(polls); i++ {
+		gaps = append(gaps, polls[i].Sub(polls[i-1]))
+	}
+	return gaps
+}
+
+func TestPollBackoff(t *testing.T) {
+	a := assert.New(t)
+	gaps := pollGaps(t, 6, "&pollInterval=10ms&maxPollInterval=40ms")
+	want := []time.Duration{10, 20, 40, 40, 40, 40}
+	for i, g := range gaps {
+		a.GreaterOrEqual(g, want[i]*time.Millisecond, "poll %d", i+1)
+	}
+	// Without the cap the last interval would be 320ms.
+	a.Less(gaps[len(gaps)-1], 160*time.Millisecond)
+}
+
+func TestLongPollingDoesNotBackOff(t *testing.T) {
+	a := assert.New(t)
+	gaps := pollGaps(t, 5, "&pollInterval=10ms&maxPollInterval=1s&longPollTimeout=1s")
+	for i, g := range gaps {
+		a.GreaterOrEqual(g, 10*time.Millisecond, "poll %d", i+1)
+	}
+	// With backoff the last interval would be 160ms.
+	a.Less(gaps[len(gaps)-1], 80*time.Millisecond)
+}
@@ -0,0 +1,142 @@
+package gohive
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// StatementResult reports the outcome of one statement of a script.
+type StatementResult struct {
+	Statement    string
+	RowsAffected int64
+	Duration     time.Duration
+	Err          error
+}
+
+// ScriptOptions controls RunScript.
+type ScriptOptions struct {
+	// ContinueOnError runs the remaining statements after a failure
+	// instead of stopping at the first one.
+	ContinueOnError bool
+	// Exec, if set, runs each statement instead of conn.ExecContext and
+	// returns the number of rows affected, or -1 if unknown. It lets callers
+	// handle statements themselves, e.g. to print the rows of queries.
+	Exec func(ctx context.Context, stmt string) (int64, error)
+}
+
+// RunScript splits script with SplitStatements and executes the statements
+// in order on conn, so that they share one hiveserver2 session and SET
+// statements affect the statements that follow. It returns a result for
+// every statement attempted, and the first error encountered.
+func RunScript(ctx context.Context, conn *sql.Conn, script string, opts ScriptOptions) ([]StatementResult, error) {
+	var results []StatementResult
+	var firstErr error
+	for _, stmt := range SplitStatements(script) {
+		start := time.Now()
+		res := StatementResult{Statement: stmt, RowsAffected: -1}
+		var err error
+		if opts.Exec != nil {
+			res.RowsAffected, err = opts.Exec(ctx, stmt)
+		} else {
+			var r sql.Result
+			if r, err = conn.ExecContext(ctx, stmt); err == nil {
+				res.RowsAffected, err = r.RowsAffected()
+			}
+		}
+		res.Duration = time.Since(start)
+		res.Err = err
+		results = append(results, res)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if !opts.ContinueOnError || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	return results, firstErr
+}
+
+// SplitStatements splits a Hive SQL script into statements separated by
+// semicolons. Semicolons inside quoted strings, backquoted identifiers and
+// comments do not end a statement. The text of SET statements is taken
+// literally up to the next semicolon. Comments are removed, except for
+// optimizer hints like /*+ MAPJOIN(t) */, and empty statements are skipped.
+func SplitStatements(script string) []string {
+	var stmts []string
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == ';':
+			flush()
+		case isSetStatement(cur.String()):
+			cur.WriteByte(c)
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end - 1
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 2
+			} else {
+				end += 2
+			}
+			if strings.HasPrefix(script[i:], "/*+") {
+				cur.WriteString(script[i : i+2+end])
+			} else {
+				cur.WriteByte(' ')
+			}
+			i += end + 1
+		case c == '\'' || c == '"' || c == '`':
+			end := closingQuote(script, i)
+			cur.WriteString(script[i:end])
+			i = end - 1
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+// closingQuote returns the index just past the quote that closes the one at
+// script[start]. Backslash escapes apply inside string literals, a doubled
+// backquote escapes itself inside identifiers.
+func closingQuote(script string, start int) int {
+	q := script[start]
+	for i := start + 1; i < len(script); i++ {
+		switch {
+		case script[i] == '\\' && q != '`':
+			i++
+		case script[i] == q:
+			if q == '`' && i+1 < len(script) && script[i+1] == '`' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(script)
+}
+
+// isSetStatement reports whether the statement text so far is a SET
+// statement, whose value is not interpreted.
+func isSetStatement(s string) bool {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	return len(s) > 3 && strings.EqualFold(s[:3], "set") && unicode.IsSpace(rune(s[3]))
+}
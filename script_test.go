@@ -0,0 +1,77 @@
+package gohive
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func TestSplitStatements(t *testing.T) {
+	for _, tc := range []struct {
+		script string
+		stmts  []string
+	}{
+		{"SELECT 1", []string{"SELECT 1"}},
+		{"SELECT 1;\n SELECT 2;;", []string{"SELECT 1", "SELECT 2"}},
+		{"SELECT 'a;b', \"c;\\\"d\" FROM `t;x`", []string{"SELECT 'a;b', \"c;\\\"d\" FROM `t;x`"}},
+		{"SELECT 'it\\'s;'; SELECT 2", []string{"SELECT 'it\\'s;'", "SELECT 2"}},
+		{"-- header; comment\nSELECT 1; -- trailing\n", []string{"SELECT 1"}},
+		{"SELECT /* a;b */ 1;", []string{"SELECT   1"}},
+		{"SELECT /*+ MAPJOIN(b) */ a FROM t", []string{"SELECT /*+ MAPJOIN(b) */ a FROM t"}},
+		{"SET mapred.job.name=it's --x;\nSELECT 1", []string{"SET mapred.job.name=it's --x", "SELECT 1"}},
+		{"set hive.exec.dynamic.partition.mode=nonstrict;INSERT INTO t VALUES ('x')",
+			[]string{"set hive.exec.dynamic.partition.mode=nonstrict", "INSERT INTO t VALUES ('x')"}},
+		{"SELECT `a``;b` FROM t", []string{"SELECT `a``;b` FROM t"}},
+		{"/* only a comment */;  ", nil},
+	} {
+		assert.Equal(t, tc.stmts, SplitStatements(tc.script), tc.script)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	a := assert.New(t)
+	srv := hivetest.NewServer("NOSASL")
+	defer srv.Close()
+	srv.HandleFunc(func(stmt string) *hivetest.Result {
+		if strings.Contains(stmt, "missing") {
+			return &hivetest.Result{ExecuteError: "Table not found missing"}
+		}
+		n := int64(2)
+		return &hivetest.Result{RowsAffected: &n}
+	})
+	conn := openInsertConn(t, srv)
+	script := "SET x=a;b;\nINSERT INTO missing VALUES (1);\n-- done\nINSERT INTO t VALUES (1), (2);"
+
+	results, err := RunScript(context.Background(), conn, script, ScriptOptions{})
+	a.ErrorContains(err, "Table not found missing")
+	a.Len(results, 3)
+	a.Equal("SET x=a", results[0].Statement)
+	a.Equal("b", results[1].Statement)
+	a.NoError(results[1].Err)
+	a.EqualValues(2, results[1].RowsAffected)
+	a.Equal(err, results[2].Err)
+	a.EqualValues(-1, results[2].RowsAffected)
+
+	results, err = RunScript(context.Background(), conn, script, ScriptOptions{ContinueOnError: true})
+	a.ErrorContains(err, "Table not found missing")
+	a.Len(results, 4)
+	a.Error(results[2].Err)
+	a.Equal("INSERT INTO t VALUES (1), (2)", results[3].Statement)
+	a.NoError(results[3].Err)
+	a.EqualValues(2, results[3].RowsAffected)
+	a.Len(srv.Statements(), 3+4)
+
+	var stmts []string
+	results, err = RunScript(context.Background(), nil, "SELECT 1; SELECT 2", ScriptOptions{
+		Exec: func(ctx context.Context, stmt string) (int64, error) {
+			stmts = append(stmts, stmt)
+			return int64(len(stmts)), nil
+		},
+	})
+	a.NoError(err)
+	a.Equal([]string{"SELECT 1", "SELECT 2"}, stmts)
+	a.EqualValues(2, results[1].RowsAffected)
+}
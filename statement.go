@@ -1,27 +1,111 @@
 package gohive
 
 import (
+	"context"
 	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
 )
 
+// hiveStmt implements database/sql/driver.Stmt. hiveserver2 has no
+// server-side prepared statements, so the driver renders the arguments
+// into the query with bindArgs.
 type hiveStmt struct {
 	hc    *hiveConnection
 	query string
 }
 
 func (stmt *hiveStmt) Close() error {
-	panic("not implemented")
+	return nil
 }
 
+// NumInput returns -1, as ConfOverlay arguments do not fill a placeholder,
+// so the number of arguments is checked by bindArgs.
 func (stmt *hiveStmt) NumInput() int {
-	panic("not implemented")
+	return -1
 }
 
-// Exec accepts stmt like: "INSERT INTO `TABLE` (f1, f2) VALUES(1.3, false)"
 func (stmt *hiveStmt) Exec(args []driver.Value) (driver.Result, error) {
-	panic("not implemented")
+	return stmt.ExecContext(context.Background(), namedValues(args))
 }
 
 func (stmt *hiveStmt) Query(args []driver.Value) (driver.Rows, error) {
-	panic("not implemented")
+	return stmt.QueryContext(context.Background(), namedValues(args))
+}
+
+func (stmt *hiveStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return stmt.hc.ExecContext(ctx, stmt.query, args)
+}
+
+func (stmt *hiveStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return stmt.hc.QueryContext(ctx, stmt.query, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+// bindArgs replaces the ? placeholders of query with the arguments that are
+// not ConfOverlay values, in order, rendered as Hive literals. Question
+// marks inside quoted strings, backquoted identifiers and comments are not
+// placeholders. The number of placeholders must match the arguments.
+func bindArgs(query string, args []driver.NamedValue) (string, error) {
+	var values []driver.NamedValue
+	for _, arg := range args {
+		if _, ok := arg.Value.(ConfOverlay); ok {
+			continue
+		}
+		if arg.Name != "" {
+			return "", errors.New("named arguments are not supported")
+		}
+		values = append(values, arg)
+	}
+	if len(values) == 0 {
+		return query, nil
+	}
+
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		end := i + 1
+		switch {
+		case c == '?':
+			if n == len(values) {
+				return "", fmt.Errorf("query has more placeholders than the %d arguments", len(values))
+			}
+			lit, err := hiveLiteral(values[n].Value)
+			if err != nil {
+				return "", fmt.Errorf("argument %d: %v", values[n].Ordinal, err)
+			}
+			b.WriteString(lit)
+			n++
+			continue
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			if end = strings.IndexByte(query[i:], '\n'); end < 0 {
+				end = len(query)
+			} else {
+				end += i
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			if end = strings.Index(query[i+2:], "*/"); end < 0 {
+				end = len(query)
+			} else {
+				end += i + 4
+			}
+		case c == '\'' || c == '"' || c == '`':
+			end = closingQuote(query, i)
+		}
+		b.WriteString(query[i:end])
+		i = end - 1
+	}
+	if n != len(values) {
+		return "", fmt.Errorf("query has %d placeholders, got %d arguments", n, len(values))
+	}
+	return b.String(), nil
 }
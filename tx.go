@@ -0,0 +1,103 @@
+package gohive
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrTxUnsupported is returned by BeginTx when hiveserver2 is not
+// configured for ACID transactions, i.e. hive.txn.manager is not
+// DbTxnManager.
+var ErrTxUnsupported = errors.New("gohive: transactions require hive.txn.manager=org.apache.hadoop.hive.ql.lockmgr.DbTxnManager")
+
+const dbTxnManager = "DbTxnManager"
+
+// hiveTx implements database/sql/driver.Tx with Hive multi-statement
+// transactions on ACID tables.
+type hiveTx struct {
+	hc *hiveConnection
+}
+
+func (tx *hiveTx) Commit() error {
+	_, err := tx.hc.ExecContext(context.Background(), "COMMIT", nil)
+	return err
+}
+
+func (tx *hiveTx) Rollback() error {
+	_, err := tx.hc.ExecContext(context.Background(), "ROLLBACK", nil)
+	return err
+}
+
+func (c *hiveConnection) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx implements database/sql/driver.ConnBeginTx. Hive only offers
+// snapshot isolation, so sql.LevelDefault and sql.LevelSnapshot are the
+// only accepted isolation levels.
+func (c *hiveConnection) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	stmt, err := startTransaction(opts)
+	if err != nil {
+		return nil, err
+	}
+	supported, err := c.supportsTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		return nil, ErrTxUnsupported
+	}
+	if _, err := c.ExecContext(ctx, stmt, nil); err != nil {
+		return nil, err
+	}
+	return &hiveTx{hc: c}, nil
+}
+
+// startTransaction renders the START TRANSACTION statement for opts.
+func startTransaction(opts driver.TxOptions) (string, error) {
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault, sql.LevelSnapshot:
+	default:
+		return "", fmt.Errorf("gohive: isolation level %s is not supported, Hive only offers snapshot isolation",
+			sql.IsolationLevel(opts.Isolation))
+	}
+	stmt := "START TRANSACTION ISOLATION LEVEL SNAPSHOT"
+	if opts.ReadOnly {
+		stmt += ", READ ONLY"
+	}
+	return stmt, nil
+}
+
+// supportsTx checks the transaction manager of the session once and caches
+// the answer.
+func (c *hiveConnection) supportsTx(ctx context.Context) (bool, error) {
+	if c.txSupported != nil {
+		return *c.txSupported, nil
+	}
+	rows, err := c.QueryContext(ctx, "SET hive.txn.manager", nil)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, 1)
+	supported := false
+	for {
+		err := rows.Next(dest)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+		if s, ok := dest[0].(string); ok && strings.HasSuffix(s, dbTxnManager) {
+			supported = true
+		}
+	}
+	c.txSupported = &supported
+	return supported, nil
+}
@@ -0,0 +1,91 @@
+package gohive
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	hiveserver2 "sqlflow.org/gohive/hiveserver2/gen-go/tcliservice"
+	"sqlflow.org/gohive/hivetest"
+)
+
+func TestStartTransaction(t *testing.T) {
+	a := assert.New(t)
+	stmt, err := startTransaction(driver.TxOptions{})
+	a.NoError(err)
+	a.Equal("START TRANSACTION ISOLATION LEVEL SNAPSHOT", stmt)
+
+	stmt, err = startTransaction(driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSnapshot), ReadOnly: true})
+	a.NoError(err)
+	a.Equal("START TRANSACTION ISOLATION LEVEL SNAPSHOT, READ ONLY", stmt)
+
+	_, err = startTransaction(driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSerializable)})
+	a.Error(err)
+}
+
+// newTxServer returns a server whose sessions use the transaction manager.
+func newTxServer(manager string) *hivetest.Server {
+	srv := hivetest.NewServer("NOSASL")
+	srv.HandleFunc(func(stmt string) *hivetest.Result {
+		if stmt == "SET hive.txn.manager" {
+			return &hivetest.Result{
+				Columns: []hivetest.Column{{Name: "set", Type: hiveserver2.TTypeId_STRING_TYPE}},
+				Rows:    [][]interface{}{{"hive.txn.manager=org.apache.hadoop.hive.ql.lockmgr." + manager}},
+			}
+		}
+		return &hivetest.Result{}
+	})
+	return srv
+}
+
+func statements(srv *hivetest.Server) []string {
+	var stmts []string
+	for _, s := range srv.Statements() {
+		stmts = append(stmts, s.Statement)
+	}
+	return stmts
+}
+
+func TestBeginTx(t *testing.T) {
+	a := assert.New(t)
+	srv := newTxServer(dbTxnManager)
+	defer srv.Close()
+	conn := openInsertConn(t, srv)
+	ctx := context.Background()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	a.NoError(err)
+	_, err = tx.Exec("INSERT INTO t VALUES (1)")
+	a.NoError(err)
+	a.NoError(tx.Commit())
+
+	tx, err = conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSnapshot, ReadOnly: true})
+	a.NoError(err)
+	a.NoError(tx.Rollback())
+
+	_, err = conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	a.Error(err)
+	a.Equal([]string{
+		"SET hive.txn.manager",
+		"START TRANSACTION ISOLATION LEVEL SNAPSHOT",
+		"INSERT INTO t VALUES (1)",
+		"COMMIT",
+		"START TRANSACTION ISOLATION LEVEL SNAPSHOT, READ ONLY",
+		"ROLLBACK",
+	}, statements(srv))
+}
+
+func TestBeginTxUnsupported(t *testing.T) {
+	a := assert.New(t)
+	srv := newTxServer("DummyTxnManager")
+	defer srv.Close()
+	conn := openInsertConn(t, srv)
+
+	_, err := conn.BeginTx(context.Background(), nil)
+	a.Equal(ErrTxUnsupported, err)
+	_, err = conn.BeginTx(context.Background(), nil)
+	a.Equal(ErrTxUnsupported, err)
+	a.Equal([]string{"SET hive.txn.manager"}, statements(srv))
+}
@@ -0,0 +1,56 @@
+package gohive
+
+import (
+	"context"
+	"regexp"
+)
+
+// Keys of TOpenSessionReq.Configuration that hiveserver2 turns into
+// hivevar and hiveconf variables of the session.
+const (
+	setHiveVarPrefix  = "set:hivevar:"
+	setHiveConfPrefix = "set:hiveconf:"
+)
+
+// HiveVars holds variables for a single query. hiveserver2 only accepts
+// hivevar variables per session, so the driver substitutes ${hivevar:name}
+// references itself before sending the query. Unqualified ${name}
+// references are left for hiveserver2, which resolves them from the hiveconf
+// variables first, as are references to unknown variables.
+//
+// Per-query hiveconf values are set with WithConfOverlay instead.
+type HiveVars map[string]string
+
+type hiveVarsKey struct{}
+
+// WithHiveVars returns a copy of ctx that substitutes vars into queries run
+// with it. Variables from enclosing contexts are kept unless overridden.
+func WithHiveVars(ctx context.Context, vars HiveVars) context.Context {
+	merged := HiveVars{}
+	if parent, ok := ctx.Value(hiveVarsKey{}).(HiveVars); ok {
+		for k, v := range parent {
+			merged[k] = v
+		}
+	}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, hiveVarsKey{}, merged)
+}
+
+var reHiveVar = regexp.MustCompile(`\$\{hivevar:([^}:]+)\}`)
+
+// substituteHiveVars replaces the variables of ctx in query.
+func substituteHiveVars(ctx context.Context, query string) string {
+	vars, ok := ctx.Value(hiveVarsKey{}).(HiveVars)
+	if !ok || len(vars) == 0 {
+		return query
+	}
+	return reHiveVar.ReplaceAllStringFunc(query, func(ref string) string {
+		name := reHiveVar.FindStringSubmatch(ref)[1]
+		if v, found := vars[name]; found {
+			return v
+		}
+		return ref
+	})
+}
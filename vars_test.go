@@ -0,0 +1,24 @@
+package gohive
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubstituteHiveVars(t *testing.T) {
+	a := assert.New(t)
+	q := "SELECT * FROM ${hivevar:tbl} WHERE dt = '${dt}' AND x = ${hiveconf:x} AND y = ${y}"
+	a.Equal(q, substituteHiveVars(context.Background(), q))
+
+	ctx := WithHiveVars(context.Background(), HiveVars{"tbl": "t1", "dt": "a"})
+	ctx = WithHiveVars(ctx, HiveVars{"dt": "2020-01-01", "x": "1"})
+	a.Equal("SELECT * FROM t1 WHERE dt = '${dt}' AND x = ${hiveconf:x} AND y = ${y}",
+		substituteHiveVars(ctx, q))
+
+	// hiveserver2 resolves ${x} from hiveconf before hivevar, so only the
+	// qualified reference is substituted.
+	q = "SELECT ${hiveconf:x}, ${x}, ${hivevar:x}"
+	a.Equal("SELECT ${hiveconf:x}, ${x}, 1", substituteHiveVars(ctx, q))
+}